@@ -0,0 +1,119 @@
+package fcache
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+const defaultBatchParallelism = 8
+
+// FileResult is the result of GetFiles for a single key.
+// Reader must be closed, if Err is nil.
+type FileResult struct {
+	Reader io.ReadCloser
+	Meta   FileMeta
+	Err    error
+}
+
+// URLResult is the result of GetURLs for a single key.
+type URLResult struct {
+	URL  string
+	Meta FileMeta
+	Err  error
+}
+
+// GetFiles gets files for all requests concurrently, within the batch
+// parallelism limit, and returns results by keys. Requests with duplicate
+// keys are made once, the first of them is used. Failure of a single
+// request doesn't affect the others.
+func (l *LoadingCache) GetFiles(ctx context.Context, reqs []GetRequest) map[string]FileResult {
+	return runBatch(ctx, l.batchParallelism(), reqs,
+		func(ctx context.Context, req GetRequest) FileResult {
+			rd, meta, err := l.GetFile(ctx, req)
+			return FileResult{Reader: rd, Meta: meta, Err: err}
+		},
+		func(err error) FileResult { return FileResult{Err: err} },
+	)
+}
+
+// GetURLs gets URLs for all requests concurrently, within the batch
+// parallelism limit, and returns results by keys. Requests with duplicate
+// keys are made once, the first of them is used. Failure of a single
+// request doesn't affect the others.
+func (l *LoadingCache) GetURLs(ctx context.Context, reqs []GetRequest, params GetURLParams) map[string]URLResult {
+	return runBatch(ctx, l.batchParallelism(), reqs,
+		func(ctx context.Context, req GetRequest) URLResult {
+			u, meta, err := l.GetURL(ctx, req, params)
+			return URLResult{URL: u, Meta: meta, Err: err}
+		},
+		func(err error) URLResult { return URLResult{Err: err} },
+	)
+}
+
+func (l *LoadingCache) batchParallelism() int {
+	if l.BatchParallelism <= 0 {
+		return defaultBatchParallelism
+	}
+	return l.BatchParallelism
+}
+
+// runBatch runs get for each unique key with at most parallelism
+// concurrent calls. Requests, which were not started before the context
+// cancellation, are resulted with failed.
+func runBatch[R any](
+	ctx context.Context,
+	parallelism int,
+	reqs []GetRequest,
+	get func(ctx context.Context, req GetRequest) R,
+	failed func(err error) R,
+) map[string]R {
+	res := make(map[string]R, len(reqs))
+	mu := sync.Mutex{}
+	set := func(key string, r R) {
+		mu.Lock()
+		defer mu.Unlock()
+		res[key] = r
+	}
+
+	sem := make(chan struct{}, parallelism)
+	wg := sync.WaitGroup{}
+	seen := make(map[string]struct{}, len(reqs))
+
+	for _, req := range reqs {
+		if _, ok := seen[req.Key]; ok {
+			continue
+		}
+		seen[req.Key] = struct{}{}
+
+		if err := acquire(ctx, sem); err != nil {
+			set(req.Key, failed(err))
+			continue
+		}
+
+		wg.Add(1)
+		go func(req GetRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			set(req.Key, get(ctx, req))
+		}(req)
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+// acquire acquires the semaphore, unless the context is done.
+func acquire(ctx context.Context, sem chan struct{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,103 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_GetFiles(t *testing.T) {
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()), WithBatchParallelism(2))
+
+	var running, maxRunning, loads int64
+	loader := func(s string, err error) Loader {
+		return func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			atomic.AddInt64(&loads, 1)
+			n := atomic.AddInt64(&running, 1)
+			defer atomic.AddInt64(&running, -1)
+			for {
+				m := atomic.LoadInt64(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if err != nil {
+				return nil, FileMeta{}, err
+			}
+			return stringReader(s), FileMeta{Size: int64(len(s))}, nil
+		}
+	}
+
+	loaderErr := errors.New("origin failure")
+	res := svc.GetFiles(context.Background(), []GetRequest{
+		{Key: "key-1", TTL: time.Hour, Loader: loader("1", nil)},
+		{Key: "key-2", TTL: time.Hour, Loader: loader("2", nil)},
+		{Key: "key-1", TTL: time.Hour, Loader: loader("duplicate", nil)},
+		{Key: "key-3", TTL: time.Hour, Loader: loader("", loaderErr)},
+		{Key: "key-4", TTL: time.Hour, Loader: loader("4", nil)},
+	})
+
+	require.Len(t, res, 4)
+	for key, expected := range map[string]string{"key-1": "1", "key-2": "2", "key-4": "4"} {
+		require.NoError(t, res[key].Err, key)
+		assertReader(t, res[key].Reader, expected)
+		assert.Equal(t, int64(1), res[key].Meta.Size)
+	}
+	assert.ErrorIs(t, res["key-3"].Err, loaderErr)
+	assert.Nil(t, res["key-3"].Reader)
+
+	assert.Equal(t, int64(4), atomic.LoadInt64(&loads))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&maxRunning))
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		res := svc.GetFiles(ctx, []GetRequest{{Key: "key-1"}, {Key: "key-2"}, {Key: "key-5"}})
+		require.Len(t, res, 3)
+		for _, r := range res {
+			assert.ErrorIs(t, r.Err, context.Canceled)
+		}
+	})
+}
+
+func TestLoadingCache_GetURLs(t *testing.T) {
+	store := &StoreMock{
+		MetaFunc: func(ctx context.Context, key string) (FileMeta, error) {
+			if key == "missing" {
+				return FileMeta{}, ErrNotFound
+			}
+			return FileMeta{Key: key}, nil
+		},
+		GetURLFunc: func(ctx context.Context, key string, params GetURLParams) (string, error) {
+			return "https://example.com/" + url.PathEscape(key), nil
+		},
+	}
+	svc := NewLoadingCache(store, WithLogger(NopLogger()))
+
+	loaderErr := errors.New("origin failure")
+	res := svc.GetURLs(context.Background(), []GetRequest{
+		{Key: "key-1"},
+		{Key: "key-1"},
+		{Key: "key 2"},
+		{Key: "missing", Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			return nil, FileMeta{}, loaderErr
+		}},
+	}, GetURLParams{})
+
+	assert.Equal(t, map[string]URLResult{
+		"key-1":   {URL: "https://example.com/key-1", Meta: FileMeta{Key: "key-1"}},
+		"key 2":   {URL: "https://example.com/key%202", Meta: FileMeta{Key: "key 2"}},
+		"missing": {Err: res["missing"].Err},
+	}, res)
+	assert.ErrorIs(t, res["missing"].Err, loaderErr)
+	assert.Len(t, store.MetaCalls(), 3)
+}
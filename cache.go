@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,6 +18,8 @@ const (
 )
 
 // Loader is a function to load a file in case if it's missing in cache.
+// Loader may set the file's expiration with FileMeta.WithExpiration,
+// it is used instead of the request's TTL in such case.
 type Loader func(ctx context.Context) (io.ReadCloser, FileMeta, error)
 
 // LoadingCache is a wrapper for Store, which removes file at their TTL.
@@ -27,6 +29,21 @@ type LoadingCache struct {
 	Options
 	CacheStats
 
+	inflight  inflight
+	access    accessIndex
+	failures  failureIndex
+	refreshes refreshIndex
+	evictCh   chan struct{}
+
+	bgMu     sync.Mutex
+	bg       sync.WaitGroup // background loads
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
+	shutdown bool
+
+	refreshSem    chan struct{} // limits the number of refresh workers
+	revalidateSem chan struct{} // limits the number of stale reloads
+
 	// mockable fields
 	now func() time.Time
 }
@@ -39,27 +56,68 @@ func NewLoadingCache(backend Store, opts ...Option) *LoadingCache {
 			InvalidatePeriod: 15 * time.Minute,
 			Log:              stdLogger{},
 		},
-		now: time.Now,
+		evictCh: make(chan struct{}, 1),
+		now:     time.Now,
 	}
 
 	for _, opt := range opts {
 		opt(&res.Options)
 	}
 
+	if res.RefreshAhead > 0 {
+		workers := res.RefreshWorkers
+		if workers <= 0 {
+			workers = defaultRefreshWorkers
+		}
+		res.refreshSem = make(chan struct{}, workers)
+	}
+
+	workers := res.RevalidateWorkers
+	if workers <= 0 {
+		workers = defaultRevalidateWorkers
+	}
+	res.revalidateSem = make(chan struct{}, workers)
+
 	return res
 }
 
 // GetFile gets the file from cache or loads it, if absent.
 func (l *LoadingCache) GetFile(ctx context.Context, req GetRequest) (rd io.ReadCloser, meta FileMeta, err error) {
-	if meta, err = l.Store.Meta(ctx, req.Key); err == nil {
+	ctx, span := l.tracer().Start(ctx, "fcache.GetFile", StringAttr(AttrKey, req.Key))
+	rd, meta, err = l.getFile(ctx, span, req)
+	endSpan(span, err, Int64Attr(AttrSize, meta.Size))
+
+	if rd != nil && l.Metrics != nil {
+		rd = countReader(rd, l.Metrics.BytesServed)
+	}
+	return rd, meta, err
+}
+
+func (l *LoadingCache) getFile(ctx context.Context, span Span, req GetRequest) (rd io.ReadCloser, meta FileMeta, err error) {
+	meta, state, err := l.lookup(ctx, req)
+	if err != nil {
+		// store returned unexpected error
+		l.fail(ctx, OpMeta, req.Key, FileMeta{}, err)
+		return nil, FileMeta{}, fmt.Errorf("get file from storage: %w", err)
+	}
+
+	if state != stateMissing {
 		// cache hit
-		atomic.AddInt64(&l.Hits, 1)
+		l.hit(ctx, OpGetFile, req.Key, meta)
+		span.SetAttributes(StringAttr(AttrResult, state.result()))
 
-		if rd, err = l.Store.Get(ctx, req.Key); err != nil {
-			atomic.AddInt64(&l.Errors, 1)
+		if rd, err = l.openFile(ctx, req, meta); err != nil {
+			l.fail(ctx, OpGet, req.Key, meta, err)
 			return rd, meta, fmt.Errorf("get file reader: %w", err)
 		}
 
+		l.touch(req.Key, false)
+
+		if state == stateStale {
+			// file is being reloaded, no need to extend its TTL
+			return rd, meta, nil
+		}
+
 		if meta, err = l.extendTTL(ctx, req.Key, req.TTL, meta); err != nil {
 			return rd, meta, fmt.Errorf("extend file's TTL: %w", err)
 		}
@@ -67,43 +125,129 @@ func (l *LoadingCache) GetFile(ctx context.Context, req GetRequest) (rd io.ReadC
 		return rd, meta, nil
 	}
 
-	if err != nil && !errors.Is(err, ErrNotFound) {
-		// store returned unexpected error
-		atomic.AddInt64(&l.Errors, 1)
-		return nil, FileMeta{}, fmt.Errorf("get file from storage: %w", err)
+	if req.Loader == nil {
+		// nothing to load the file with
+		l.miss(ctx, OpGetFile, req.Key)
+		span.SetAttributes(StringAttr(AttrResult, state.result()))
+		return nil, FileMeta{}, ErrNotFound
+	}
+
+	if err = l.failureFor(req.Key); err != nil {
+		return nil, FileMeta{}, err
+	}
+
+	c, leader := l.inflight.join(req.Key)
+	if !leader {
+		l.coalesced(OpGetFile)
+		span.SetAttributes(StringAttr(AttrResult, resultCoalesced))
+		return l.awaitFile(ctx, c, req.Key)
 	}
 
 	// miss
-	atomic.AddInt64(&l.Misses, 1)
+	l.miss(ctx, OpGetFile, req.Key)
+	span.SetAttributes(StringAttr(AttrResult, state.result()))
+
+	if req.Stream {
+		return l.streamFile(ctx, req, func(meta FileMeta, err error) {
+			l.inflight.finish(req.Key, c, meta, nil, err)
+		})
+	}
+
+	rd, meta, spool, err := l.loadFile(ctx, req)
+	l.inflight.finish(req.Key, c, meta, spool, err)
 
-	originalRd, meta, err := req.Loader(ctx)
+	return rd, meta, err
+}
+
+// loadFile loads the file with the request's loader and puts it to the store.
+// The returned spool is shared with the concurrent callers, waiting for
+// the same key.
+func (l *LoadingCache) loadFile(ctx context.Context, req GetRequest) (io.ReadCloser, FileMeta, spool, error) {
+	originalRd, meta, err := l.callLoader(ctx, req)
 	if err != nil {
-		return nil, FileMeta{}, fmt.Errorf("loader returned error: %w", err)
+		return nil, FileMeta{}, nil, fmt.Errorf("loader returned error: %w", err)
 	}
 
 	// duplicating reader to still return file content, when reader is emptied
-	tmp, err := os.CreateTemp(os.TempDir(), "fcache_*")
+	sp, err := l.newSpool(meta)
 	if err != nil {
-		return nil, FileMeta{}, fmt.Errorf("create temp file: %w", err)
+		_ = originalRd.Close()
+		return nil, FileMeta{}, nil, err
 	}
-	putRd := io.TeeReader(originalRd, tmp)
-	rd = &tempFile{File: tmp} // wrap file to delete it immediately, when is closed
+	putRd := io.TeeReader(originalRd, sp)
 
-	if meta.Meta == nil {
-		meta.Meta = map[string]string{}
+	meta = l.loadedMeta(meta, req)
+
+	err = l.traced(ctx, "fcache.Store.Put", req.Key, func(ctx context.Context) error {
+		return l.Store.Put(ctx, req.Key, meta, io.NopCloser(putRd))
+	})
+	if cerr := originalRd.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("close reader, received from loader: %w", cerr)
+	}
+	if serr := sp.seal(); serr != nil && err == nil {
+		err = serr
+	}
+	if err != nil {
+		l.fail(ctx, OpPut, req.Key, meta, err)
+		sp.release()
+		return nil, meta, nil, fmt.Errorf("put file into storage: %w", err)
+	}
+
+	l.stored(ctx, req.Key, meta)
+	l.loaded(req, meta)
+
+	// leader's reader holds the reference, acquired on spool creation
+	rd, err := sp.open()
+	if err != nil {
+		return nil, meta, nil, err
+	}
+
+	return rd, meta, sp, nil
+}
+
+// newSpool makes a spool to keep the content of the loaded file. Small files
+// are kept in memory, if their size is known in advance.
+func (l *LoadingCache) newSpool(meta FileMeta) (spool, error) {
+	if l.MemoryBufferThreshold > 0 && meta.Size > 0 && meta.Size <= l.MemoryBufferThreshold {
+		sp := &spoolMemory{}
+		sp.buf.Grow(int(meta.Size))
+		return sp, nil
 	}
-	meta.Meta[metaInvalidateAtKey] = l.now().Add(req.TTL).Format(metaTimeFormat)
 
-	if err = l.Store.Put(ctx, req.Key, meta, io.NopCloser(putRd)); err != nil {
-		return rd, meta, fmt.Errorf("put file into storage: %w", err)
+	return newSpoolFile(l.SpillDir)
+}
+
+// awaitFile waits for the concurrent load of the same key and returns its
+// result with an independent reader of the content.
+func (l *LoadingCache) awaitFile(ctx context.Context, c *call, key string) (io.ReadCloser, FileMeta, error) {
+	if err := l.inflight.wait(ctx, c); err != nil {
+		return nil, FileMeta{}, fmt.Errorf("wait for concurrent load: %w", err)
 	}
 
-	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
-		return rd, meta, fmt.Errorf("reset temp file caret to file start: %w", err)
+	if c.err != nil {
+		return nil, FileMeta{}, fmt.Errorf("concurrent load: %w", c.err)
 	}
 
-	if err = originalRd.Close(); err != nil {
-		return rd, meta, fmt.Errorf("close reader, received from loader: %w", err)
+	meta := copyMeta(c.meta)
+
+	if c.content != nil {
+		rd, err := c.content.open()
+		if err != nil {
+			l.fail(ctx, OpGet, key, meta, err)
+			return nil, meta, err
+		}
+		return rd, meta, nil
+	}
+
+	// file has been loaded without keeping the content, reading it from store
+	var rd io.ReadCloser
+	err := l.traced(ctx, "fcache.Store.Get", key, func(ctx context.Context) (err error) {
+		rd, err = l.Store.Get(ctx, key)
+		return err
+	})
+	if err != nil {
+		l.fail(ctx, OpGet, key, meta, err)
+		return nil, meta, fmt.Errorf("get file reader: %w", err)
 	}
 
 	return rd, meta, nil
@@ -111,19 +255,48 @@ func (l *LoadingCache) GetFile(ctx context.Context, req GetRequest) (rd io.ReadC
 
 // GetURL returns the URL from the cache backend.
 func (l *LoadingCache) GetURL(ctx context.Context, req GetRequest, params GetURLParams) (url string, meta FileMeta, err error) {
-	getURL := func(meta FileMeta) (string, FileMeta, error) {
-		u, err := l.Store.GetURL(ctx, req.Key, params)
+	ctx, span := l.tracer().Start(ctx, "fcache.GetURL", StringAttr(AttrKey, req.Key))
+	url, meta, err = l.getURL(ctx, span, req, params)
+	endSpan(span, err, Int64Attr(AttrSize, meta.Size))
+	return url, meta, err
+}
+
+func (l *LoadingCache) getURL(
+	ctx context.Context,
+	span Span,
+	req GetRequest,
+	params GetURLParams,
+) (url string, meta FileMeta, err error) {
+	getURL := func(meta FileMeta) (u string, _ FileMeta, err error) {
+		err = l.traced(ctx, "fcache.Store.GetURL", req.Key, func(ctx context.Context) (err error) {
+			u, err = l.Store.GetURL(ctx, req.Key, params)
+			return err
+		})
 		if err != nil {
-			atomic.AddInt64(&l.Errors, 1)
+			l.fail(ctx, OpGetURL, req.Key, meta, err)
 			return "", FileMeta{}, fmt.Errorf("get url from storage: %w", err)
 		}
 
 		return u, meta, nil
 	}
 
-	if meta, err = l.Store.Meta(ctx, req.Key); err == nil {
+	meta, state, err := l.lookup(ctx, req)
+	if err != nil {
+		// store returned unexpected error
+		l.fail(ctx, OpMeta, req.Key, FileMeta{}, err)
+		return "", FileMeta{}, fmt.Errorf("get file meta from storage: %w", err)
+	}
+
+	if state != stateMissing {
 		// cache hit
-		atomic.AddInt64(&l.Hits, 1)
+		l.hit(ctx, OpGetURL, req.Key, meta)
+		span.SetAttributes(StringAttr(AttrResult, state.result()))
+		l.touch(req.Key, false)
+
+		if state == stateStale {
+			// file is being reloaded, no need to extend its TTL
+			return getURL(meta)
+		}
 
 		if meta, err = l.extendTTL(ctx, req.Key, req.TTL, meta); err != nil {
 			return "", meta, fmt.Errorf("extend file's TTL: %w", err)
@@ -132,48 +305,169 @@ func (l *LoadingCache) GetURL(ctx context.Context, req GetRequest, params GetURL
 		return getURL(meta)
 	}
 
-	if err != nil && !errors.Is(err, ErrNotFound) {
-		// store returned unexpected error
-		atomic.AddInt64(&l.Errors, 1)
-		return "", FileMeta{}, fmt.Errorf("get file meta from storage: %w", err)
+	if req.Loader == nil {
+		// nothing to load the file with
+		l.miss(ctx, OpGetURL, req.Key)
+		span.SetAttributes(StringAttr(AttrResult, state.result()))
+		return "", FileMeta{}, ErrNotFound
+	}
+
+	if err = l.failureFor(req.Key); err != nil {
+		return "", FileMeta{}, err
+	}
+
+	c, leader := l.inflight.join(req.Key)
+	if !leader {
+		l.coalesced(OpGetURL)
+		span.SetAttributes(StringAttr(AttrResult, resultCoalesced))
+
+		if err = l.inflight.wait(ctx, c); err != nil {
+			return "", FileMeta{}, fmt.Errorf("wait for concurrent load: %w", err)
+		}
+		if c.content != nil {
+			c.content.release() // content is not needed to form URL
+		}
+		if c.err != nil {
+			return "", FileMeta{}, fmt.Errorf("concurrent load: %w", c.err)
+		}
+
+		return getURL(copyMeta(c.meta))
 	}
 
 	// miss
-	atomic.AddInt64(&l.Misses, 1)
+	l.miss(ctx, OpGetURL, req.Key)
+	span.SetAttributes(StringAttr(AttrResult, state.result()))
 
-	rd, meta, err := req.Loader(ctx)
+	meta, err = l.load(ctx, req)
+	l.inflight.finish(req.Key, c, meta, nil, err)
 	if err != nil {
-		atomic.AddInt64(&l.Errors, 1)
-		return "", FileMeta{}, fmt.Errorf("loader returned error: %w", err)
+		return "", FileMeta{}, err
 	}
 
-	if meta.Meta == nil {
-		meta.Meta = map[string]string{}
+	return getURL(meta)
+}
+
+// load loads the file with the request's loader and puts it to the store.
+func (l *LoadingCache) load(ctx context.Context, req GetRequest) (FileMeta, error) {
+	rd, meta, err := l.callLoader(ctx, req)
+	if err != nil {
+		return FileMeta{}, fmt.Errorf("loader returned error: %w", err)
 	}
-	meta.Meta[metaInvalidateAtKey] = l.now().Add(req.TTL).Format(metaTimeFormat)
 
-	if err = l.Store.Put(ctx, req.Key, meta, rd); err != nil {
-		atomic.AddInt64(&l.Errors, 1)
-		return "", FileMeta{}, fmt.Errorf("put file into storage: %w", err)
+	meta = l.loadedMeta(meta, req)
+
+	err = l.traced(ctx, "fcache.Store.Put", req.Key, func(ctx context.Context) error {
+		return l.Store.Put(ctx, req.Key, meta, rd)
+	})
+	if err != nil {
+		l.fail(ctx, OpPut, req.Key, meta, err)
+		return FileMeta{}, fmt.Errorf("put file into storage: %w", err)
 	}
 
-	return getURL(meta)
+	l.stored(ctx, req.Key, meta)
+	l.loaded(req, meta)
+
+	return meta, nil
+}
+
+// Set puts the file into the cache with the given TTL, replacing the
+// previous one, if any.
+func (l *LoadingCache) Set(ctx context.Context, key string, ttl time.Duration, meta FileMeta, rd io.ReadCloser) error {
+	meta = l.withTTL(copyMeta(meta), ttl)
+
+	err := l.traced(ctx, "fcache.Store.Put", key, func(ctx context.Context) error {
+		return l.Store.Put(ctx, key, meta, rd)
+	})
+	if err != nil {
+		l.fail(ctx, OpPut, key, meta, err)
+		return fmt.Errorf("put file into storage: %w", err)
+	}
+
+	l.failures.forget(key)
+	l.refreshes.forget(key)
+	l.stored(ctx, key, meta)
+	l.log(ctx, LevelDebug, "set file", StringAttr(LogKey, key), StringAttr(LogOp, string(OpSet)))
+
+	return nil
+}
+
+// Delete removes the file from the cache.
+// ErrNotFound is returned, if the file is absent.
+func (l *LoadingCache) Delete(ctx context.Context, key string) error {
+	l.failures.forget(key)
+	l.access.remove(key)
+	l.refreshes.forget(key)
+
+	err := l.traced(ctx, "fcache.Store.Remove", key, func(ctx context.Context) error {
+		return l.Store.Remove(ctx, key)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			l.fail(ctx, OpRemove, key, FileMeta{}, err)
+		}
+		return fmt.Errorf("remove file from storage: %w", err)
+	}
+
+	l.removed(ctx, key, FileMeta{}, RemoveDeleted)
+
+	l.log(ctx, LevelDebug, "deleted file", StringAttr(LogKey, key), StringAttr(LogOp, string(OpDelete)))
+
+	return nil
+}
+
+// Touch sets the file's TTL to the given one from now and returns its meta.
+// ErrNotFound is returned, if the file is absent.
+func (l *LoadingCache) Touch(ctx context.Context, key string, ttl time.Duration) (meta FileMeta, err error) {
+	err = l.traced(ctx, "fcache.Store.Meta", key, func(ctx context.Context) (err error) {
+		meta, err = l.Store.Meta(ctx, key)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			l.fail(ctx, OpMeta, key, FileMeta{}, err)
+		}
+		return FileMeta{}, fmt.Errorf("get file meta from storage: %w", err)
+	}
+
+	meta = l.withTTL(meta, ttl)
+
+	err = l.traced(ctx, "fcache.Store.UpdateMeta", key, func(ctx context.Context) error {
+		return l.Store.UpdateMeta(ctx, key, meta)
+	})
+	if err != nil {
+		l.fail(ctx, OpUpdateMeta, key, meta, err)
+		return FileMeta{}, fmt.Errorf("update file meta: %w", err)
+	}
+
+	l.touch(key, false)
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnExtendTTL(ctx, key, meta) })
+	l.log(ctx, LevelDebug, "touched file",
+		StringAttr(LogKey, key),
+		StringAttr(LogOp, string(OpTouch)),
+		StringAttr("expires_at", meta.Meta[metaInvalidateAtKey]),
+	)
+
+	return meta, nil
 }
 
 // CacheStats represent stat values.
 type CacheStats struct {
 	Hits   int64
 	Misses int64
-	Errors int64
+	// Coalesced is the number of misses, which waited for the concurrent
+	// load of the same key instead of running the loader.
+	Coalesced int64
+	Errors    int64
 	StoreStats
 }
 
 // Stat returns cache stats
 func (l *LoadingCache) Stat(ctx context.Context) (CacheStats, error) {
 	res := CacheStats{
-		Hits:   l.Hits,
-		Misses: l.Misses,
-		Errors: l.Errors,
+		Hits:      atomic.LoadInt64(&l.Hits),
+		Misses:    atomic.LoadInt64(&l.Misses),
+		Coalesced: atomic.LoadInt64(&l.Coalesced),
+		Errors:    atomic.LoadInt64(&l.Errors),
 	}
 
 	storeStats, err := l.Store.Stat(ctx)
@@ -188,7 +482,8 @@ func (l *LoadingCache) Stat(ctx context.Context) (CacheStats, error) {
 }
 
 // Run runs invalidation goroutine. It will check for files TTL expiration
-// and, if it expires, removes it manually.
+// and, if it expires, removes it manually. If the cache has size limits,
+// it also evicts files, when the store exceeds them.
 func (l *LoadingCache) Run(ctx context.Context) error {
 	if l.InvalidatePeriod == 0 {
 		return errors.New("invalidation period cannot be zero")
@@ -198,20 +493,63 @@ func (l *LoadingCache) Run(ctx context.Context) error {
 	for {
 		select {
 		case <-ticker.C:
+			start := time.Now()
 			invalidated, err := l.Invalidate(ctx)
+			attrs := []Attr{
+				StringAttr(LogOp, string(OpInvalidate)),
+				DurationAttr(LogDuration, time.Since(start)),
+				Int64Attr(LogCount, invalidated),
+			}
 			if err != nil {
-				l.Log.Printf("[WARN] failed to invalidate cache items: %v", err)
+				l.log(ctx, LevelWarn, "failed to invalidate cache items", append(attrs, ErrorAttr(err))...)
 			}
-			l.Log.Printf("[DEBUG] invalidated %d items", invalidated)
+			l.log(ctx, LevelDebug, "invalidated cache items", attrs...)
+			l.failures.cleanup(l.now())
+			l.evict(ctx)
+		case <-l.evictCh:
+			l.evict(ctx)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
 
-// Invalidate invalidates expired cache items.
+// Shutdown cancels background reloads of files and waits for them to
+// finish or for the context to be done. Files are not reloaded in background
+// after Shutdown.
+func (l *LoadingCache) Shutdown(ctx context.Context) error {
+	l.bgMu.Lock()
+	l.shutdown = true
+	if l.bgCancel != nil {
+		l.bgCancel()
+	}
+	l.bgMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.bg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for background reloads: %w", ctx.Err())
+	}
+}
+
+// Invalidate invalidates expired cache items. Files, loaded with
+// GetRequest.MaxStale, are kept till the end of their stale window.
 // Used for tests.
 func (l *LoadingCache) Invalidate(ctx context.Context) (invalidated int64, err error) {
+	start := time.Now()
+	ctx, span := l.tracer().Start(ctx, "fcache.Invalidate")
+	defer func() {
+		endSpan(span, err, Int64Attr(AttrCount, invalidated))
+		l.metrics().Invalidated(time.Since(start), invalidated, err)
+	}()
+
 	files, err := l.Store.List(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("list objects from store: %w", err)
@@ -231,19 +569,48 @@ func (l *LoadingCache) Invalidate(ctx context.Context) (invalidated int64, err e
 		if err != nil {
 			errs = multierror.Append(errs, fmt.Errorf("parse invalidate_at time: %w", err))
 		}
+		// expired file is kept, while it may be served as stale
+		if staleUntil, ok := file.staleUntil(); ok && staleUntil.After(invalidateAt) {
+			invalidateAt = staleUntil
+		}
 		if invalidateAt.Before(l.now()) {
 			if err = l.Store.Remove(ctx, file.Key); err != nil {
 				errs = multierror.Append(err, fmt.Errorf("remove file under key %q: %w", file.Key, err))
 				continue
 			}
+			l.access.remove(file.Key)
+			l.refreshes.forget(file.Key)
+			l.removed(ctx, file.Key, file, RemoveExpired)
 			invalidated++
+			l.log(ctx, LevelDebug, "removed expired file",
+				StringAttr(LogKey, file.Key),
+				StringAttr(LogOp, string(OpInvalidate)),
+			)
 		}
-		l.Log.Printf("[DEBUG] removed file with key %q", file.Key)
 	}
 
 	return invalidated, errs.ErrorOrNil()
 }
 
+// evict evicts files and logs the result.
+func (l *LoadingCache) evict(ctx context.Context) {
+	if !l.evictionEnabled() {
+		return
+	}
+
+	start := time.Now()
+	evicted, err := l.Evict(ctx)
+	attrs := []Attr{
+		StringAttr(LogOp, string(OpEvict)),
+		DurationAttr(LogDuration, time.Since(start)),
+		Int64Attr(LogCount, evicted),
+	}
+	if err != nil {
+		l.log(ctx, LevelWarn, "failed to evict cache items", append(attrs, ErrorAttr(err))...)
+	}
+	l.log(ctx, LevelDebug, "evicted cache items", attrs...)
+}
+
 func (l *LoadingCache) extendTTL(ctx context.Context, key string, ttl time.Duration, meta FileMeta) (FileMeta, error) {
 	if !l.ExtendTTL {
 		return meta, nil
@@ -253,33 +620,51 @@ func (l *LoadingCache) extendTTL(ctx context.Context, key string, ttl time.Durat
 		meta.Meta = map[string]string{}
 	}
 
-	v, ok := meta.Meta[metaInvalidateAtKey]
-	if !ok {
-		meta.Meta[metaInvalidateAtKey] = l.now().Add(ttl).Format(metaTimeFormat)
+	if v, ok := meta.Meta[metaInvalidateAtKey]; ok {
+		tm, err := time.Parse(metaTimeFormat, v)
+		if err != nil {
+			return meta, fmt.Errorf("parse invalidate_at time: %w", err)
+		}
+		meta.Meta[metaInvalidateAtKey] = tm.Add(ttl).Format(metaTimeFormat)
+	} else {
+		meta = l.withTTL(meta, ttl)
 	}
 
-	tm, err := time.Parse(metaTimeFormat, v)
+	err := l.traced(ctx, "fcache.Store.UpdateMeta", key, func(ctx context.Context) error {
+		return l.Store.UpdateMeta(ctx, key, meta)
+	})
 	if err != nil {
-		return meta, fmt.Errorf("parse invalidate_at time: %w", err)
-	}
-
-	meta.Meta[metaInvalidateAtKey] = tm.Add(ttl).Format(metaTimeFormat)
-
-	if err = l.Store.UpdateMeta(ctx, key, meta); err != nil {
+		l.fail(ctx, OpUpdateMeta, key, meta, err)
 		return meta, fmt.Errorf("update file meta: %w", err)
 	}
 
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnExtendTTL(ctx, key, meta) })
+
 	return meta, nil
 }
 
-type tempFile struct{ *os.File }
-
-func (t *tempFile) Close() error {
-	if err := t.File.Close(); err != nil {
-		return fmt.Errorf("close file: %w", err)
+// loadedMeta prepares the meta of the loaded file to be put into the store.
+func (l *LoadingCache) loadedMeta(meta FileMeta, req GetRequest) FileMeta {
+	meta = meta.WithTags(req.Tags...)
+	if _, ok := meta.ExpiresAt(); !ok {
+		// expiration isn't set by the loader
+		meta = l.withTTL(meta, req.TTL)
 	}
-	if err := os.Remove(t.Name()); err != nil {
-		return fmt.Errorf("remove file: %w", err)
+	return withStaleWindow(meta, req.MaxStale)
+}
+
+// WithExpiration returns the copy of meta with the given expiration time.
+func (m FileMeta) WithExpiration(t time.Time) FileMeta {
+	m = copyMeta(m)
+	m.Meta[metaInvalidateAtKey] = t.Format(metaTimeFormat)
+	return m
+}
+
+// withTTL sets the file's expiration time to the given TTL from now.
+func (l *LoadingCache) withTTL(meta FileMeta, ttl time.Duration) FileMeta {
+	if meta.Meta == nil {
+		meta.Meta = map[string]string{}
 	}
-	return nil
+	meta.Meta[metaInvalidateAtKey] = l.now().Add(ttl).Format(metaTimeFormat)
+	return meta
 }
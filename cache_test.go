@@ -2,6 +2,7 @@ package fcache
 
 import (
 	"context"
+	"errors"
 	"io"
 	"sort"
 	"strings"
@@ -304,3 +305,64 @@ func TestLoadingCache_Invalidation(t *testing.T) {
 		assert.Equal(t, 1, len(store.ListCalls()))
 	})
 }
+
+func TestLoadingCache_Set(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	store := NewMemory(0, NopLogger())
+	svc := NewLoadingCache(store, WithLogger(NopLogger()), WithNegativeCaching(time.Hour, nil))
+	svc.now = func() time.Time { return now }
+
+	svc.failures.remember("key", errors.New("origin failure"), now.Add(time.Hour))
+
+	meta := FileMeta{Name: "a.txt", Meta: map[string]string{"k": "v"}}
+	err := svc.Set(context.Background(), "key", 15*time.Minute, meta,
+		io.NopCloser(strings.NewReader("some file data")))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k": "v"}, meta.Meta, "caller's meta must not be modified")
+
+	rd, res, err := svc.GetFile(context.Background(), GetRequest{Key: "key"})
+	require.NoError(t, err)
+	assertReader(t, rd, "some file data")
+	assert.Equal(t, map[string]string{
+		"k":                 "v",
+		metaInvalidateAtKey: now.Add(15 * time.Minute).Format(metaTimeFormat),
+	}, res.Meta)
+	assert.Equal(t, CacheStats{Hits: 1}, svc.CacheStats)
+}
+
+func TestLoadingCache_Delete(t *testing.T) {
+	store := NewMemory(0, NopLogger())
+	svc := NewLoadingCache(store, WithLogger(NopLogger()))
+
+	require.NoError(t, svc.Set(context.Background(), "key", time.Minute, FileMeta{},
+		io.NopCloser(strings.NewReader("some file data"))))
+
+	require.NoError(t, svc.Delete(context.Background(), "key"))
+	_, err := store.Meta(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, svc.Delete(context.Background(), "key"), ErrNotFound)
+	assert.Equal(t, int64(0), svc.Errors)
+}
+
+func TestLoadingCache_Touch(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	store := NewMemory(0, NopLogger())
+	svc := NewLoadingCache(store, WithLogger(NopLogger()))
+	svc.now = func() time.Time { return now }
+
+	require.NoError(t, store.Put(context.Background(), "key", FileMeta{Name: "a.txt"},
+		io.NopCloser(strings.NewReader("some file data"))))
+
+	meta, err := svc.Touch(context.Background(), "key", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", meta.Name)
+	assert.Equal(t, now.Add(time.Hour).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+
+	meta, err = store.Meta(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+
+	_, err = svc.Touch(context.Background(), "absent", time.Hour)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
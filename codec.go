@@ -0,0 +1,62 @@
+package fcache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Codec encodes values of type T into files and decodes them back.
+type Codec[T any] interface {
+	Encode(w io.Writer, v T) error
+	Decode(r io.Reader) (T, error)
+	// Mime returns the content type of encoded values.
+	Mime() string
+}
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec[T any] struct{}
+
+// Encode implements Codec.
+func (JSONCodec[T]) Encode(w io.Writer, v T) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+}
+
+// Decode implements Codec.
+func (JSONCodec[T]) Decode(r io.Reader) (T, error) {
+	var v T
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return v, fmt.Errorf("decode json: %w", err)
+	}
+	return v, nil
+}
+
+// Mime implements Codec.
+func (JSONCodec[T]) Mime() string { return "application/json" }
+
+// GobCodec encodes values with encoding/gob.
+type GobCodec[T any] struct{}
+
+// Encode implements Codec.
+func (GobCodec[T]) Encode(w io.Writer, v T) error {
+	if err := gob.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("encode gob: %w", err)
+	}
+	return nil
+}
+
+// Decode implements Codec.
+func (GobCodec[T]) Decode(r io.Reader) (T, error) {
+	var v T
+	if err := gob.NewDecoder(r).Decode(&v); err != nil {
+		return v, fmt.Errorf("decode gob: %w", err)
+	}
+	return v, nil
+}
+
+// Mime implements Codec.
+func (GobCodec[T]) Mime() string { return "application/x-gob" }
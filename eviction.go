@@ -0,0 +1,192 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// EvictionCandidate describes the cached file, which might be evicted.
+type EvictionCandidate struct {
+	FileMeta
+	// LastAccess is the time of the last hit or put of the file, made by
+	// this process. Zero, if the file hasn't been accessed since start.
+	LastAccess time.Time
+	// Accesses is the number of hits and puts of the file, made by this
+	// process.
+	Accesses int64
+}
+
+// lastUsed returns the time of the last access to the file, assuming that
+// files, which weren't accessed by this process, were used at creation.
+func (c EvictionCandidate) lastUsed() time.Time {
+	if c.LastAccess.IsZero() {
+		return c.CreatedAt
+	}
+	return c.LastAccess
+}
+
+// EvictionPolicy defines the order, in which files are evicted, when the
+// cache exceeds its size limits.
+type EvictionPolicy interface {
+	// Less reports whether a must be evicted before b.
+	Less(a, b EvictionCandidate) bool
+}
+
+// EvictionPolicyFunc is an adapter to use ordinary functions as
+// EvictionPolicy.
+type EvictionPolicyFunc func(a, b EvictionCandidate) bool
+
+// Less calls f(a, b).
+func (f EvictionPolicyFunc) Less(a, b EvictionCandidate) bool { return f(a, b) }
+
+var (
+	// EvictLRU evicts least recently used files first.
+	EvictLRU EvictionPolicy = EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+		return a.lastUsed().Before(b.lastUsed())
+	})
+
+	// EvictLFU evicts least frequently used files first, least recently used
+	// ones among equally used files.
+	EvictLFU EvictionPolicy = EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+		if a.Accesses != b.Accesses {
+			return a.Accesses < b.Accesses
+		}
+		return a.lastUsed().Before(b.lastUsed())
+	})
+
+	// EvictOldest evicts files with the oldest creation time first.
+	EvictOldest EvictionPolicy = EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+)
+
+// Evict removes files from the store, until it fits the size limits,
+// in the order, defined by the eviction policy.
+func (l *LoadingCache) Evict(ctx context.Context) (evicted int64, err error) {
+	if !l.evictionEnabled() {
+		return 0, nil
+	}
+
+	stat, err := l.Store.Stat(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get store stats: %w", err)
+	}
+
+	if l.fits(stat) {
+		return 0, nil
+	}
+
+	files, err := l.Store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list objects from store: %w", err)
+	}
+
+	candidates := make([]EvictionCandidate, 0, len(files))
+	for _, file := range files {
+		info := l.access.get(file.Key)
+		candidates = append(candidates, EvictionCandidate{
+			FileMeta:   file,
+			LastAccess: info.last,
+			Accesses:   info.count,
+		})
+	}
+
+	policy := l.EvictionPolicy
+	if policy == nil {
+		policy = EvictLRU
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return policy.Less(candidates[i], candidates[j]) })
+
+	errs := &multierror.Error{}
+
+	for _, c := range candidates {
+		if l.fits(stat) {
+			break
+		}
+
+		if err = l.Store.Remove(ctx, c.Key); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = multierror.Append(errs, fmt.Errorf("remove file under key %q: %w", c.Key, err))
+			continue
+		}
+
+		l.access.remove(c.Key)
+		l.refreshes.forget(c.Key)
+		l.removed(ctx, c.Key, c.FileMeta, RemoveEvicted)
+		stat.Keys--
+		stat.Size -= c.Size
+		evicted++
+		l.log(ctx, LevelDebug, "evicted file", StringAttr(LogKey, c.Key), StringAttr(LogOp, string(OpEvict)))
+	}
+
+	return evicted, errs.ErrorOrNil()
+}
+
+// evictionEnabled reports whether the cache has size limits.
+func (l *LoadingCache) evictionEnabled() bool { return l.MaxSize > 0 || l.MaxKeys > 0 }
+
+// fits reports whether the store stats are within the size limits.
+func (l *LoadingCache) fits(stat StoreStats) bool {
+	return (l.MaxSize <= 0 || stat.Size <= l.MaxSize) && (l.MaxKeys <= 0 || stat.Keys <= l.MaxKeys)
+}
+
+// touch records the access to the file and, if the file has been just put,
+// requests eviction.
+func (l *LoadingCache) touch(key string, put bool) {
+	if !l.evictionEnabled() {
+		return
+	}
+
+	l.access.touch(key, l.now())
+
+	if !put {
+		return
+	}
+
+	select {
+	case l.evictCh <- struct{}{}:
+	default: // eviction has been already requested
+	}
+}
+
+// accessIndex keeps track of accesses to cached files in this process.
+type accessIndex struct {
+	mu    sync.Mutex
+	items map[string]accessInfo
+}
+
+type accessInfo struct {
+	last  time.Time
+	count int64
+}
+
+func (a *accessIndex) touch(key string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.items == nil {
+		a.items = map[string]accessInfo{}
+	}
+
+	info := a.items[key]
+	info.last = now
+	info.count++
+	a.items[key] = info
+}
+
+func (a *accessIndex) get(key string) accessInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.items[key]
+}
+
+func (a *accessIndex) remove(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.items, key)
+}
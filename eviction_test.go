@@ -0,0 +1,112 @@
+package fcache
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_Evict(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	prepare := func(t *testing.T, opts ...Option) *LoadingCache {
+		store := NewMemory(0, NopLogger())
+		svc := NewLoadingCache(store, append(opts, WithLogger(NopLogger()))...)
+
+		for i, key := range []string{"key-1", "key-2", "key-3"} {
+			tm := now.Add(time.Duration(i) * time.Minute)
+			store.now = func() time.Time { return tm }
+			svc.now = func() time.Time { return tm }
+			rd, _, err := svc.GetFile(context.Background(), GetRequest{
+				Key:    key,
+				TTL:    time.Hour,
+				Loader: stringLoader("1234"),
+			})
+			require.NoError(t, err)
+			require.NoError(t, rd.Close())
+		}
+
+		// key-1 is the most recently used, key-3 is the most frequently used
+		for i, key := range []string{"key-3", "key-3", "key-1"} {
+			tm := now.Add(time.Duration(10+i) * time.Minute)
+			svc.now = func() time.Time { return tm }
+			rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: key})
+			require.NoError(t, err)
+			require.NoError(t, rd.Close())
+		}
+
+		return svc
+	}
+
+	tbl := []struct {
+		name     string
+		opts     []Option
+		expected []string
+	}{
+		{name: "no limits", expected: []string{"key-1", "key-2", "key-3"}},
+		{name: "lru by size", opts: []Option{WithMaxSize(8)}, expected: []string{"key-1", "key-3"}},
+		{name: "lru by keys", opts: []Option{WithMaxKeys(1)}, expected: []string{"key-1"}},
+		{
+			name:     "lfu",
+			opts:     []Option{WithMaxKeys(1), WithEvictionPolicy(EvictLFU)},
+			expected: []string{"key-3"},
+		},
+		{
+			name:     "oldest",
+			opts:     []Option{WithMaxSize(8), WithEvictionPolicy(EvictOldest)},
+			expected: []string{"key-2", "key-3"},
+		},
+		{name: "fits", opts: []Option{WithMaxSize(12), WithMaxKeys(3)}, expected: []string{"key-1", "key-2", "key-3"}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := prepare(t, tt.opts...)
+
+			evicted, err := svc.Evict(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(3-len(tt.expected)), evicted)
+
+			keys, err := svc.Store.Keys(context.Background())
+			require.NoError(t, err)
+			sort.Strings(keys)
+			assert.Equal(t, tt.expected, keys)
+		})
+	}
+}
+
+func TestLoadingCache_Run_Evict(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := NewLoadingCache(NewMemory(0, NopLogger()),
+		WithLogger(NopLogger()),
+		WithMaxKeys(1),
+		WithInvalidationPeriod(time.Hour),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.ErrorIs(t, svc.Run(ctx), context.Canceled)
+	}()
+
+	for _, key := range []string{"key-1", "key-2"} {
+		rd, _, err := svc.GetFile(ctx, GetRequest{Key: key, TTL: time.Hour, Loader: stringLoader("1234")})
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+	}
+
+	require.Eventually(t, func() bool {
+		stat, err := svc.Store.Stat(ctx)
+		require.NoError(t, err)
+		return stat.Keys == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,621 @@
+// Package fakes3 provides the in-memory S3-compatible HTTP server for tests.
+package fakes3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5" //nolint:gosec // S3 uses MD5 for ETags
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	amzMetaPrefix        = "X-Amz-Meta-"
+	streamingPayload     = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	defaultMaxKeys       = 1000
+	defaultContentType   = "binary/octet-stream"
+	responseHeaderPrefix = "response-"
+)
+
+// Server is an in-memory S3-compatible HTTP server, which supports
+// path-style requests for object operations, used by fcache.S3:
+// HeadObject, GetObject with ranges and presigned URLs, PutObject with
+// streaming signatures, multipart uploads, CopyObject, DeleteObject,
+// ListObjectsV2, including MinIO's extension to list objects with
+// metadata, and GetBucketLocation. Signatures are not verified.
+// Use it with httptest.NewServer.
+type Server struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*object // bucket -> key -> object
+	uploads map[string]*upload
+
+	// mockable fields
+	now func() time.Time
+}
+
+type object struct {
+	data         []byte
+	contentType  string
+	meta         http.Header // X-Amz-Meta-* headers
+	etag         string
+	lastModified time.Time
+}
+
+type upload struct {
+	bucket, key string
+	contentType string
+	meta        http.Header
+	parts       map[int][]byte
+}
+
+// New makes new instance of Server with the given buckets.
+func New(buckets ...string) *Server {
+	s := &Server{
+		buckets: map[string]map[string]*object{},
+		uploads: map[string]*upload{},
+		now:     time.Now,
+	}
+
+	for _, bkt := range buckets {
+		s.CreateBucket(bkt)
+	}
+
+	return s
+}
+
+// CreateBucket creates the bucket, if absent.
+func (s *Server) CreateBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[name]; !ok {
+		s.buckets[name] = map[string]*object{}
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bkt, key := splitPath(r.URL.Path)
+	q := r.URL.Query()
+
+	if bkt == "" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "listing buckets is not supported")
+		return
+	}
+
+	if key == "" {
+		s.serveBucket(w, r, bkt, q)
+		return
+	}
+
+	if !s.bucketExists(bkt) {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		s.createUpload(w, r, bkt, key)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		s.completeUpload(w, r, bkt, key, q.Get("uploadId"))
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		s.uploadPart(w, r, q.Get("uploadId"), q.Get("partNumber"))
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		s.abortUpload(w, q.Get("uploadId"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, bkt, key)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, bkt, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.getObject(w, r, bkt, key, q)
+	case r.Method == http.MethodDelete:
+		s.deleteObject(w, bkt, key)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "the method is not allowed")
+	}
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, bkt string, q url.Values) {
+	switch {
+	case r.Method == http.MethodPut:
+		s.CreateBucket(bkt)
+		w.WriteHeader(http.StatusOK)
+		return
+	case !s.bucketExists(bkt):
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+		return
+	case r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && q.Has("location"):
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Value   string   `xml:",chardata"`
+		}{Value: "us-east-1"})
+	case r.Method == http.MethodGet && q.Get("list-type") == "2":
+		s.listObjects(w, r, bkt, q)
+	default:
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "the bucket operation is not supported")
+	}
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bkt, key string) {
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	obj := s.store(bkt, key, &object{data: data, contentType: r.Header.Get("Content-Type"), meta: metaHeaders(r.Header)})
+	w.Header().Set("ETag", obj.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, bkt, key string) {
+	src, err := url.PathUnescape(strings.SplitN(r.Header.Get("X-Amz-Copy-Source"), "?", 2)[0])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid copy source")
+		return
+	}
+	srcBkt, srcKey := splitPath(src)
+
+	srcObj, ok := s.object(srcBkt, srcKey)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+
+	contentType, meta := srcObj.contentType, srcObj.meta
+	if strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		contentType, meta = r.Header.Get("Content-Type"), metaHeaders(r.Header)
+	}
+
+	obj := s.store(bkt, key, &object{data: srcObj.data, contentType: contentType, meta: meta})
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: obj.etag, LastModified: obj.lastModified.Format(time.RFC3339Nano)})
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bkt, key string, q url.Values) {
+	obj, ok := s.object(bkt, key)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+
+	h := w.Header()
+	for k, v := range obj.meta {
+		h[k] = v
+	}
+	h.Set("Content-Type", obj.contentType)
+	h.Set("ETag", obj.etag)
+	h.Set("Last-Modified", obj.lastModified.UTC().Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+
+	// presigned URLs may override response headers
+	for k, v := range q {
+		if strings.HasPrefix(k, responseHeaderPrefix) && len(v) > 0 {
+			h.Set(strings.TrimPrefix(k, responseHeaderPrefix), v[0])
+		}
+	}
+
+	if rng := r.Header.Get("Range"); rng != "" && !validRange(rng, int64(len(obj.data))) {
+		writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange",
+			"the requested range is not satisfiable")
+		return
+	}
+
+	http.ServeContent(w, r, "", obj.lastModified, bytes.NewReader(obj.data))
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, bkt, key string) {
+	s.mu.Lock()
+	delete(s.buckets[bkt], key)
+	s.mu.Unlock()
+
+	// S3 doesn't report absence of the object on removal
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bkt string, q url.Values) {
+	maxKeys := defaultMaxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid max-keys")
+			return
+		}
+		maxKeys = n
+	}
+
+	after := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+			return
+		}
+		after = string(b)
+	}
+
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	withMeta := q.Get("metadata") == "true"
+	encode := func(s string) string { return s }
+	if q.Get("encoding-type") == "url" {
+		encode = url.QueryEscape
+	}
+
+	res := listResult{
+		Name:              bkt,
+		Prefix:            encode(prefix),
+		Delimiter:         encode(delimiter),
+		MaxKeys:           maxKeys,
+		ContinuationToken: q.Get("continuation-token"),
+		StartAfter:        encode(q.Get("start-after")),
+		EncodingType:      q.Get("encoding-type"),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.buckets[bkt]))
+	for key := range s.buckets[bkt] {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	prefixes := map[string]bool{}
+	for _, key := range keys {
+		if res.KeyCount >= maxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(res.lastKey))
+			break
+		}
+
+		res.lastKey = key
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				if !prefixes[p] {
+					prefixes[p] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: encode(p)})
+					res.KeyCount++
+				}
+				continue
+			}
+		}
+
+		obj := s.buckets[bkt][key]
+		content := listContent{
+			Key:          encode(key),
+			LastModified: obj.lastModified.UTC().Format(time.RFC3339Nano),
+			ETag:         obj.etag,
+			Size:         int64(len(obj.data)),
+			StorageClass: "STANDARD",
+		}
+		if withMeta {
+			content.UserMetadata = &userMetadata{"content-type": obj.contentType}
+			for k, v := range obj.meta {
+				(*content.UserMetadata)[k] = v[0]
+			}
+		}
+		res.Contents = append(res.Contents, content)
+		res.KeyCount++
+	}
+
+	writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, bkt, key string) {
+	id := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s/%s/%d", bkt, key, s.now().UnixNano())))) //nolint:gosec
+
+	s.mu.Lock()
+	s.uploads[id] = &upload{
+		bucket:      bkt,
+		key:         key,
+		contentType: r.Header.Get("Content-Type"),
+		meta:        metaHeaders(r.Header),
+		parts:       map[int][]byte{},
+	}
+	s.mu.Unlock()
+
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadID string `xml:"UploadId"`
+	}{Bucket: bkt, Key: key, UploadID: id})
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, id, partNumber string) {
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid part number")
+		return
+	}
+
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	up, ok := s.uploads[id]
+	if ok {
+		up.parts[n] = data
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+		return
+	}
+
+	w.Header().Set("ETag", etag(data))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, bkt, key, id string) {
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	up, ok := s.uploads[id]
+	delete(s.uploads, id)
+	s.mu.Unlock()
+
+	if !ok || up.bucket != bkt || up.key != key {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+		return
+	}
+
+	var data []byte
+	sums := md5.New() //nolint:gosec
+	for _, part := range req.Parts {
+		pd, ok := up.parts[part.PartNumber]
+		if !ok || etag(pd) != `"`+strings.Trim(part.ETag, `"`)+`"` {
+			writeError(w, r, http.StatusBadRequest, "InvalidPart", "one or more of the specified parts could not be found")
+			return
+		}
+		data = append(data, pd...)
+		sum := md5.Sum(pd) //nolint:gosec
+		_, _ = sums.Write(sum[:])
+	}
+
+	obj := s.store(bkt, key, &object{
+		data:        data,
+		contentType: up.contentType,
+		meta:        up.meta,
+		etag:        fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sums.Sum(nil)), len(req.Parts)),
+	})
+
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Location string
+		Bucket   string
+		Key      string
+		ETag     string
+	}{Location: r.URL.Path, Bucket: bkt, Key: key, ETag: obj.etag})
+}
+
+func (s *Server) abortUpload(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// store puts the object into the bucket, setting its defaults.
+func (s *Server) store(bkt, key string, obj *object) *object {
+	if obj.contentType == "" {
+		obj.contentType = defaultContentType
+	}
+	if obj.etag == "" {
+		obj.etag = etag(obj.data)
+	}
+	obj.lastModified = s.now()
+
+	s.mu.Lock()
+	s.buckets[bkt][key] = obj
+	s.mu.Unlock()
+
+	return obj
+}
+
+func (s *Server) object(bkt, key string) (*object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.buckets[bkt][key]
+	return obj, ok
+}
+
+func (s *Server) bucketExists(bkt string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.buckets[bkt]
+	return ok
+}
+
+// listResult is the response to ListObjectsV2.
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	EncodingType          string `xml:",omitempty"`
+	KeyCount              int
+	MaxKeys               int
+	IsTruncated           bool
+	Contents              []listContent
+	CommonPrefixes        []commonPrefix
+
+	lastKey string
+}
+
+type listContent struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+	UserMetadata *userMetadata `xml:",omitempty"`
+}
+
+type commonPrefix struct {
+	Prefix string
+}
+
+// userMetadata is marshaled as elements, named by its keys, as MinIO does.
+type userMetadata map[string]string
+
+// MarshalXML implements xml.Marshaler.
+func (m userMetadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := e.EncodeElement(m[k], xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// splitPath splits the path-style request path to the bucket and the key.
+func splitPath(p string) (bkt, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// metaHeaders returns user metadata headers.
+func metaHeaders(h http.Header) http.Header {
+	res := http.Header{}
+	for k, v := range h {
+		if strings.HasPrefix(k, amzMetaPrefix) {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// readBody reads the body of the request, decoding it, if it's sent with
+// the streaming signature.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Header.Get("X-Amz-Content-Sha256") != streamingPayload {
+		return io.ReadAll(r.Body)
+	}
+
+	var res []byte
+	rd := bufio.NewReader(r.Body)
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("read chunk header: %w", err)
+		}
+
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(line), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse chunk size: %w", err)
+		}
+
+		chunk := make([]byte, size+2) // with trailing CRLF
+		if _, err = io.ReadFull(rd, chunk); err != nil {
+			return nil, fmt.Errorf("read chunk: %w", err)
+		}
+
+		if size == 0 {
+			return res, nil
+		}
+		res = append(res, chunk[:size]...)
+	}
+}
+
+// validRange reports whether the range header is satisfiable.
+// S3 serves the whole object for invalid range headers, but reports error
+// for ranges, which start after the end of the object.
+func validRange(rng string, size int64) bool {
+	spec := strings.TrimPrefix(rng, "bytes=")
+	if spec == rng || strings.Contains(spec, ",") {
+		return true
+	}
+
+	start := strings.SplitN(spec, "-", 2)[0]
+	if start == "" {
+		return size > 0
+	}
+
+	n, err := strconv.ParseInt(start, 10, 64)
+	return err != nil || n < size
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data) //nolint:gosec
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+
+	bkt, key := splitPath(r.URL.Path)
+	writeXML(w, status, struct {
+		XMLName    xml.Name `xml:"Error"`
+		Code       string
+		Message    string
+		BucketName string `xml:",omitempty"`
+		Key        string `xml:",omitempty"`
+		Resource   string
+	}{Code: code, Message: msg, BucketName: bkt, Key: key, Resource: r.URL.Path})
+}
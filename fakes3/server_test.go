@@ -0,0 +1,205 @@
+package fakes3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+
+	prepare := func(t *testing.T) *minio.Client {
+		ts := httptest.NewServer(New("bucket"))
+		t.Cleanup(ts.Close)
+
+		cl, err := minio.New(strings.TrimPrefix(ts.URL, "http://"), &minio.Options{
+			Creds: credentials.NewStaticV4("access-key", "secret-key", ""),
+		})
+		require.NoError(t, err)
+		return cl
+	}
+
+	put := func(t *testing.T, cl *minio.Client, key, content string, opts minio.PutObjectOptions) {
+		_, err := cl.PutObject(ctx, "bucket", key, strings.NewReader(content), int64(len(content)), opts)
+		require.NoError(t, err)
+	}
+
+	read := func(t *testing.T, rd io.ReadCloser) string {
+		b, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+		return string(b)
+	}
+
+	t.Run("put and stat", func(t *testing.T) {
+		cl := prepare(t)
+		put(t, cl, "key", "some file data", minio.PutObjectOptions{
+			ContentType:  "text/plain",
+			UserMetadata: map[string]string{"_invalidate_at": "some time", "custom": "value"},
+		})
+
+		oi, err := cl.StatObject(ctx, "bucket", "key", minio.StatObjectOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(14), oi.Size)
+		assert.Equal(t, "text/plain", oi.ContentType)
+		assert.Equal(t, map[string]string{"_invalidate_at": "some time", "Custom": "value"}, map[string]string(oi.UserMetadata))
+		assert.False(t, oi.LastModified.IsZero())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		cl := prepare(t)
+
+		_, err := cl.StatObject(ctx, "bucket", "absent", minio.StatObjectOptions{})
+		errResp := minio.ToErrorResponse(err)
+		assert.Equal(t, http.StatusNotFound, errResp.StatusCode)
+		assert.Equal(t, "NoSuchKey", errResp.Code)
+
+		obj, err := cl.GetObject(ctx, "bucket", "absent", minio.GetObjectOptions{})
+		require.NoError(t, err)
+		_, err = io.ReadAll(obj)
+		assert.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code)
+
+		_, err = cl.StatObject(ctx, "absent", "key", minio.StatObjectOptions{})
+		assert.Equal(t, http.StatusNotFound, minio.ToErrorResponse(err).StatusCode)
+
+		// S3 doesn't report absence on removal
+		require.NoError(t, cl.RemoveObject(ctx, "bucket", "absent", minio.RemoveObjectOptions{}))
+	})
+
+	t.Run("range", func(t *testing.T) {
+		cl := prepare(t)
+		put(t, cl, "key", "0123456789", minio.PutObjectOptions{})
+
+		opts := minio.GetObjectOptions{}
+		require.NoError(t, opts.SetRange(3, 6))
+		obj, err := cl.GetObject(ctx, "bucket", "key", opts)
+		require.NoError(t, err)
+		assert.Equal(t, "3456", read(t, obj))
+
+		opts = minio.GetObjectOptions{}
+		require.NoError(t, opts.SetRange(0, -3))
+		obj, err = cl.GetObject(ctx, "bucket", "key", opts)
+		require.NoError(t, err)
+		assert.Equal(t, "789", read(t, obj))
+	})
+
+	t.Run("multipart upload of unknown size", func(t *testing.T) {
+		cl := prepare(t)
+		content := strings.Repeat("0123456789", 1<<20) // 10 MiB
+
+		_, err := cl.PutObject(ctx, "bucket", "key", strings.NewReader(content), -1, minio.PutObjectOptions{
+			ContentType:  "text/plain",
+			UserMetadata: map[string]string{"custom": "value"},
+			PartSize:     5 << 20,
+		})
+		require.NoError(t, err)
+
+		obj, err := cl.GetObject(ctx, "bucket", "key", minio.GetObjectOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, content, read(t, obj))
+
+		oi, err := cl.StatObject(ctx, "bucket", "key", minio.StatObjectOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", oi.ContentType)
+		assert.Equal(t, "value", oi.UserMetadata["Custom"])
+		assert.True(t, strings.HasSuffix(oi.ETag, "-2"), oi.ETag)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		cl := prepare(t)
+		put(t, cl, "key", "some file data", minio.PutObjectOptions{
+			ContentType:  "text/plain",
+			UserMetadata: map[string]string{"old": "value"},
+		})
+
+		_, err := cl.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: "bucket", Object: "copy"},
+			minio.CopySrcOptions{Bucket: "bucket", Object: "key"},
+		)
+		require.NoError(t, err)
+
+		_, err = cl.CopyObject(ctx,
+			minio.CopyDestOptions{
+				Bucket:          "bucket",
+				Object:          "key",
+				ReplaceMetadata: true,
+				UserMetadata:    map[string]string{"new": "value", "Content-Type": "text/csv"},
+			},
+			minio.CopySrcOptions{Bucket: "bucket", Object: "key"},
+		)
+		require.NoError(t, err)
+
+		oi, err := cl.StatObject(ctx, "bucket", "copy", minio.StatObjectOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", oi.ContentType)
+		assert.Equal(t, map[string]string{"Old": "value"}, map[string]string(oi.UserMetadata))
+
+		oi, err = cl.StatObject(ctx, "bucket", "key", minio.StatObjectOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "text/csv", oi.ContentType)
+		assert.Equal(t, int64(14), oi.Size)
+		assert.Equal(t, map[string]string{"New": "value"}, map[string]string(oi.UserMetadata))
+
+		_, err = cl.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: "bucket", Object: "key"},
+			minio.CopySrcOptions{Bucket: "bucket", Object: "absent"},
+		)
+		assert.Equal(t, http.StatusNotFound, minio.ToErrorResponse(err).StatusCode)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		cl := prepare(t)
+		for _, key := range []string{"dir/a b", "dir/b+c", "dir/sub/c", "other"} {
+			put(t, cl, key, "1234", minio.PutObjectOptions{ContentType: "text/plain", UserMetadata: map[string]string{"k": "v"}})
+		}
+
+		var keys []string
+		for oi := range cl.ListObjects(ctx, "bucket", minio.ListObjectsOptions{
+			Prefix:       "dir/",
+			Recursive:    true,
+			WithMetadata: true,
+			MaxKeys:      1,
+		}) {
+			require.NoError(t, oi.Err)
+			keys = append(keys, oi.Key)
+			assert.Equal(t, int64(4), oi.Size)
+			assert.Equal(t, map[string]string{"content-type": "text/plain", "X-Amz-Meta-K": "v"},
+				map[string]string(oi.UserMetadata))
+		}
+		assert.Equal(t, []string{"dir/a b", "dir/b+c", "dir/sub/c"}, keys)
+
+		keys = nil
+		for oi := range cl.ListObjects(ctx, "bucket", minio.ListObjectsOptions{Prefix: "dir/"}) {
+			require.NoError(t, oi.Err)
+			keys = append(keys, oi.Key)
+		}
+		assert.Equal(t, []string{"dir/a b", "dir/b+c", "dir/sub/"}, keys)
+	})
+
+	t.Run("presigned url", func(t *testing.T) {
+		cl := prepare(t)
+		put(t, cl, "key", "some file data", minio.PutObjectOptions{})
+
+		u, err := cl.PresignedGetObject(ctx, "bucket", "key", time.Minute, url.Values{
+			"response-content-disposition": []string{"attachment; filename=a.txt"},
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Get(u.String())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "attachment; filename=a.txt", resp.Header.Get("Content-Disposition"))
+		assert.Equal(t, "some file data", read(t, resp.Body))
+	})
+}
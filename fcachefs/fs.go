@@ -0,0 +1,265 @@
+// Package fcachefs exposes fcache.Store as a read-only io/fs file system.
+package fcachefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Semior001/fcache"
+)
+
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// FS is a read-only fs.FS over the store. Keys, separated by slashes, are
+// mapped to files in directories, directories exist as long as there are
+// files in them. Keys, which are not valid paths, according to fs.ValidPath,
+// are not accessible. Files implement io.Seeker and io.ReaderAt, if the
+// store implements fcache.RangeGetter and reports their sizes.
+type FS struct {
+	ctx   context.Context
+	store fcache.Store
+}
+
+// New makes new instance of FS over the given store. The context is used
+// for all requests to the store.
+func New(ctx context.Context, store fcache.Store) *FS {
+	return &FS{ctx: ctx, store: store}
+}
+
+// Open implements fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	meta, err := f.meta(name)
+	switch {
+	case errors.Is(err, fcache.ErrNotFound):
+		entries, err := f.readDir(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &dir{info: dirInfo(name), entries: entries}, nil
+	case err != nil:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	// files of unknown size are read as a whole
+	if rg, ok := f.store.(fcache.RangeGetter); ok && meta.Size > 0 {
+		return &seekableFile{
+			info:        fileInfo{meta: meta},
+			RangeReader: fcache.NewRangeReader(f.ctx, rg, name, meta.Size),
+		}, nil
+	}
+
+	rd, err := f.store.Get(f.ctx, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: translate(err)}
+	}
+
+	return &file{info: fileInfo{meta: meta}, ReadCloser: rd}, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	meta, err := f.meta(name)
+	switch {
+	case errors.Is(err, fcache.ErrNotFound):
+		if _, err = f.readDir(name); err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+		return dirInfo(name), nil
+	case err != nil:
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return fileInfo{meta: meta}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return entries, nil
+}
+
+// meta returns the meta of the file, stored by the key.
+// It returns fcache.ErrNotFound for the root directory.
+func (f *FS) meta(name string) (fcache.FileMeta, error) {
+	if name == "." {
+		return fcache.FileMeta{}, fcache.ErrNotFound
+	}
+
+	meta, err := f.store.Meta(f.ctx, name)
+	if err != nil {
+		return fcache.FileMeta{}, err
+	}
+
+	meta.Key = name
+	return meta, nil
+}
+
+// readDir returns the sorted entries of the directory.
+// It returns fs.ErrNotExist, if there are no files in it, unless it's
+// the root directory.
+func (f *FS) readDir(name string) ([]fs.DirEntry, error) {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	files, err := f.list(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]bool{}
+	var res []fs.DirEntry
+	for _, meta := range files {
+		if !strings.HasPrefix(meta.Key, prefix) || !fs.ValidPath(meta.Key) {
+			continue
+		}
+
+		child := strings.TrimPrefix(meta.Key, prefix)
+		if i := strings.IndexByte(child, '/'); i >= 0 {
+			if sub := child[:i]; !dirs[sub] {
+				dirs[sub] = true
+				res = append(res, fs.FileInfoToDirEntry(dirInfo(prefix+sub)))
+			}
+			continue
+		}
+
+		res = append(res, fs.FileInfoToDirEntry(fileInfo{meta: meta}))
+	}
+
+	if len(res) == 0 && name != "." {
+		return nil, fs.ErrNotExist
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
+
+// list returns files with the given key prefix, or all files, if the store
+// doesn't implement fcache.PrefixLister.
+func (f *FS) list(prefix string) ([]fcache.FileMeta, error) {
+	if pl, ok := f.store.(fcache.PrefixLister); ok && prefix != "" {
+		res, err := pl.ListPrefix(f.ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("list files with prefix %q: %w", prefix, err)
+		}
+		return res, nil
+	}
+
+	res, err := f.store.List(f.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	return res, nil
+}
+
+// translate replaces fcache.ErrNotFound with fs.ErrNotExist.
+func translate(err error) error {
+	if errors.Is(err, fcache.ErrNotFound) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// file is a file, read from the store from the beginning.
+type file struct {
+	info fileInfo
+	io.ReadCloser
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// seekableFile is a file, read from the store by ranges.
+type seekableFile struct {
+	info fileInfo
+	*fcache.RangeReader
+}
+
+func (f *seekableFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *seekableFile) Read(p []byte) (int, error) {
+	n, err := f.RangeReader.Read(p)
+	return n, translate(err)
+}
+
+// dir is a directory with the entries, listed on open.
+type dir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dir) Close() error { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
+
+// fileInfo describes the file by its meta.
+type fileInfo struct{ meta fcache.FileMeta }
+
+func (i fileInfo) Name() string       { return path.Base(i.meta.Key) }
+func (i fileInfo) Size() int64        { return i.meta.Size }
+func (i fileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i fileInfo) ModTime() time.Time { return i.meta.CreatedAt }
+func (i fileInfo) IsDir() bool        { return false }
+
+// Sys returns fcache.FileMeta of the file.
+func (i fileInfo) Sys() interface{} { return i.meta }
+
+// dirInfo describes the directory.
+type dirInfo string
+
+func (i dirInfo) Name() string       { return path.Base(string(i)) }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{}   { return nil }
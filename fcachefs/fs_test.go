@@ -0,0 +1,127 @@
+package fcachefs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Semior001/fcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	files := map[string]string{
+		"index.html":          "<html></html>",
+		"css/style.css":       "body {}",
+		"css/vendor/lib.css":  "p {}",
+		"img/logo.svg":        "<svg></svg>",
+		"/invalid/leading":    "not accessible",
+		"invalid//separators": "not accessible",
+	}
+
+	prepare := func(t *testing.T) fcache.Store {
+		store := fcache.NewMemory(0, fcache.NopLogger())
+		for key, content := range files {
+			err := store.Put(context.Background(), key, fcache.FileMeta{Size: int64(len(content))},
+				io.NopCloser(strings.NewReader(content)))
+			require.NoError(t, err)
+		}
+		return store
+	}
+
+	expected := []string{"index.html", "css/style.css", "css/vendor/lib.css", "img/logo.svg"}
+
+	t.Run("range getter", func(t *testing.T) {
+		require.NoError(t, fstest.TestFS(New(context.Background(), prepare(t)), expected...))
+	})
+
+	t.Run("plain store", func(t *testing.T) {
+		store := struct{ fcache.Store }{prepare(t)}
+		require.NoError(t, fstest.TestFS(New(context.Background(), store), expected...))
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		fsys := New(context.Background(), unknownSizeStore{Memory: prepare(t).(*fcache.Memory)})
+
+		b, err := fs.ReadFile(fsys, "css/style.css")
+		require.NoError(t, err)
+		assert.Equal(t, "body {}", string(b))
+	})
+
+	t.Run("content and info", func(t *testing.T) {
+		fsys := New(context.Background(), prepare(t))
+
+		b, err := fs.ReadFile(fsys, "css/style.css")
+		require.NoError(t, err)
+		assert.Equal(t, "body {}", string(b))
+
+		fi, err := fs.Stat(fsys, "css/style.css")
+		require.NoError(t, err)
+		assert.Equal(t, "style.css", fi.Name())
+		assert.Equal(t, int64(7), fi.Size())
+		assert.False(t, fi.IsDir())
+		assert.Equal(t, "css/style.css", fi.Sys().(fcache.FileMeta).Key)
+
+		fi, err = fs.Stat(fsys, "css/vendor")
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		entries, err := fs.ReadDir(fsys, "css")
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "style.css", entries[0].Name())
+		assert.Equal(t, "vendor", entries[1].Name())
+		assert.True(t, entries[1].IsDir())
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		fsys := New(context.Background(), prepare(t))
+
+		_, err := fsys.Open("css/missing.css")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = fs.Stat(fsys, "missing")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = fs.ReadDir(fsys, "img/logo.svg")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = fsys.Open("/invalid/leading")
+		assert.True(t, errors.Is(err, fs.ErrInvalid))
+	})
+
+	t.Run("file server", func(t *testing.T) {
+		srv := httptest.NewServer(http.FileServer(http.FS(New(context.Background(), prepare(t)))))
+		defer srv.Close()
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/css/style.css", nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", "bytes=0-3")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "body", string(b))
+		assert.Equal(t, "text/css; charset=utf-8", resp.Header.Get("Content-Type"))
+	})
+}
+
+// unknownSizeStore doesn't report sizes of files.
+type unknownSizeStore struct{ *fcache.Memory }
+
+func (s unknownSizeStore) Meta(ctx context.Context, key string) (fcache.FileMeta, error) {
+	meta, err := s.Memory.Meta(ctx, key)
+	meta.Size = 0
+	return meta, err
+}
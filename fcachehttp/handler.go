@@ -0,0 +1,235 @@
+// Package fcachehttp provides HTTP helpers for serving and caching files
+// with fcache.LoadingCache.
+package fcachehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Semior001/fcache"
+)
+
+// Resolver maps the HTTP request to the cache request, i.e. to the key
+// of the file and the loader. fcache.ErrNotFound should be returned,
+// if there is no file for the request.
+type Resolver func(r *http.Request) (fcache.GetRequest, error)
+
+// ErrorHandler writes the response for the error.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Handler serves files from the cache. It supports range and conditional
+// requests, with the ETag, derived from the file's key, size and creation
+// time, and its creation time as the last modification time.
+type Handler struct {
+	cache    *fcache.LoadingCache
+	resolve  Resolver
+	log      fcache.Logger
+	onError  ErrorHandler
+	redirect bool
+	expires  time.Duration
+}
+
+// Option is a function to apply options of Handler.
+type Option func(h *Handler)
+
+// WithRedirect makes the handler redirect clients to the file's URL,
+// returned by fcache.LoadingCache.GetURL, valid for the given period,
+// instead of proxying the file's content. The content is proxied, if
+// the store doesn't support URLs or returns non-HTTP ones, like FS does.
+func WithRedirect(expires time.Duration) Option {
+	return func(h *Handler) {
+		h.redirect = true
+		h.expires = expires
+	}
+}
+
+// WithLogger sets the logger for handler.
+// `log` package is used by default.
+func WithLogger(log fcache.Logger) Option {
+	return func(h *Handler) { h.log = log }
+}
+
+// WithErrorHandler sets the writer of error responses.
+// By default, fcache.ErrNotFound leads to 404, other errors - to 500.
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(h *Handler) { h.onError = fn }
+}
+
+// NewHandler makes new instance of Handler.
+func NewHandler(cache *fcache.LoadingCache, resolve Resolver, opts ...Option) *Handler {
+	res := &Handler{
+		cache:   cache,
+		resolve: resolve,
+		log:     stdLogger{},
+	}
+	res.onError = res.writeError
+
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	return res
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.resolve(r)
+	if err != nil {
+		h.onError(w, r, fmt.Errorf("resolve request: %w", err))
+		return
+	}
+
+	if h.redirect && h.serveRedirect(w, r, req) {
+		return
+	}
+
+	req.Seekable = true
+
+	rd, meta, err := h.cache.GetFile(r.Context(), req)
+	if err != nil {
+		h.onError(w, r, fmt.Errorf("get file: %w", err))
+		return
+	}
+	defer func() {
+		if err := rd.Close(); err != nil {
+			h.log.Printf("[WARN] failed to close reader of file with key %q: %v", req.Key, err)
+		}
+	}()
+
+	setHeaders(w, req.Key, meta)
+
+	if rs, ok := rd.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, meta.Name, meta.CreatedAt, rs)
+		return
+	}
+
+	// reader is not seekable, serving the whole content
+	if checkNotModified(w, r, req.Key, meta) {
+		return
+	}
+
+	if meta.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err = io.Copy(w, rd); err != nil {
+		h.log.Printf("[WARN] failed to write file with key %q: %v", req.Key, err)
+	}
+}
+
+// serveRedirect redirects the client to the file's URL. It reports false,
+// if the store doesn't support URLs or returns the URL, which is not
+// reachable by the client, e.g. the path to the local file.
+func (h *Handler) serveRedirect(w http.ResponseWriter, r *http.Request, req fcache.GetRequest) bool {
+	u, _, err := h.cache.GetURL(r.Context(), req, fcache.GetURLParams{Expires: h.expires})
+	if errors.Is(err, fcache.ErrNotSupported) {
+		return false
+	}
+	if err != nil {
+		h.onError(w, r, fmt.Errorf("get url: %w", err))
+		return true
+	}
+
+	if pu, err := url.Parse(u); err != nil || (pu.Scheme != "http" && pu.Scheme != "https") {
+		return false
+	}
+
+	http.Redirect(w, r, u, http.StatusFound)
+	return true
+}
+
+// setHeaders sets headers, describing the file.
+func setHeaders(w http.ResponseWriter, key string, meta fcache.FileMeta) {
+	if meta.Mime != "" {
+		w.Header().Set("Content-Type", meta.Mime)
+	}
+	if meta.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": meta.Name,
+		}))
+	}
+	w.Header().Set("ETag", etag(key, meta))
+	if !meta.CreatedAt.IsZero() {
+		w.Header().Set("Last-Modified", meta.CreatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkNotModified writes 304 response, if the client's copy of the file
+// is up-to-date.
+func checkNotModified(w http.ResponseWriter, r *http.Request, key string, meta fcache.FileMeta) bool {
+	notModified := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		notModified = inm == "*" || inm == etag(key, meta)
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !meta.CreatedAt.IsZero() {
+		notModified = !meta.CreatedAt.Truncate(time.Second).After(ims)
+	}
+
+	if !notModified {
+		return false
+	}
+
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Disposition")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etag returns the entity tag of the file.
+func etag(key string, meta fcache.FileMeta) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", key, meta.Size, meta.CreatedAt.UnixNano())))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// writeError is the default error handler.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, fcache.ErrNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	h.log.Printf("[WARN] failed to serve %s: %v", r.URL.Path, err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, args ...interface{}) { log.Printf(format, args...) }
+
+// PathResolver resolves the request's URL path without the prefix as the
+// key of the file with the given TTL, and makes the file's loader with
+// the given function, if any.
+func PathResolver(prefix string, ttl time.Duration, loader func(key string) fcache.Loader) Resolver {
+	return func(r *http.Request) (fcache.GetRequest, error) {
+		key := strings.TrimPrefix(r.URL.Path, prefix)
+		if key == "" || len(key) == len(r.URL.Path) && prefix != "" {
+			return fcache.GetRequest{}, fcache.ErrNotFound
+		}
+
+		req := fcache.GetRequest{Key: key, TTL: ttl}
+		if loader != nil {
+			req.Loader = loader(key)
+		}
+
+		return req, nil
+	}
+}
@@ -0,0 +1,214 @@
+package fcachehttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	createdAt := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	loads := 0
+	svc := fcache.NewLoadingCache(fcache.NewMemory(0, fcache.NopLogger()), fcache.WithLogger(fcache.NopLogger()))
+	h := NewHandler(svc, PathResolver("/files/", time.Hour, func(key string) fcache.Loader {
+		return func(ctx context.Context) (io.ReadCloser, fcache.FileMeta, error) {
+			if key != "video.mp4" {
+				return nil, fcache.FileMeta{}, fcache.ErrNotFound
+			}
+			loads++
+			return io.NopCloser(strings.NewReader("0123456789")), fcache.FileMeta{
+				Name:      "video.mp4",
+				Mime:      "video/mp4",
+				Size:      10,
+				CreatedAt: createdAt,
+			}, nil
+		}
+	}), WithLogger(fcache.NopLogger()))
+
+	do := func(method, path string, headers map[string]string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	body := func(resp *http.Response) string {
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return string(b)
+	}
+
+	t.Run("miss", func(t *testing.T) {
+		resp := do(http.MethodGet, "/files/video.mp4", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "0123456789", body(resp))
+		assert.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+		assert.Equal(t, `attachment; filename=video.mp4`, resp.Header.Get("Content-Disposition"))
+		assert.Equal(t, createdAt.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+	})
+
+	hit := do(http.MethodHead, "/files/video.mp4", nil)
+	etag := hit.Header.Get("ETag")
+	lastModified, err := http.ParseTime(hit.Header.Get("Last-Modified"))
+	require.NoError(t, err)
+
+	t.Run("range", func(t *testing.T) {
+		resp := do(http.MethodGet, "/files/video.mp4", map[string]string{"Range": "bytes=2-5"})
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "2345", body(resp))
+		assert.Equal(t, "bytes 2-5/10", resp.Header.Get("Content-Range"))
+	})
+
+	t.Run("if-none-match", func(t *testing.T) {
+		resp := do(http.MethodGet, "/files/video.mp4", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Empty(t, body(resp))
+	})
+
+	t.Run("if-modified-since", func(t *testing.T) {
+		resp := do(http.MethodGet, "/files/video.mp4", map[string]string{
+			"If-Modified-Since": lastModified.Format(http.TimeFormat),
+		})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+		resp = do(http.MethodGet, "/files/video.mp4", map[string]string{
+			"If-Modified-Since": lastModified.Add(-time.Minute).Format(http.TimeFormat),
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "0123456789", body(resp))
+	})
+
+	t.Run("head", func(t *testing.T) {
+		resp := do(http.MethodHead, "/files/video.mp4", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+		assert.Empty(t, body(resp))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/files/missing", nil).StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/files/", nil).StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/other/video.mp4", nil).StatusCode)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		resp := do(http.MethodPost, "/files/video.mp4", nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "GET, HEAD", resp.Header.Get("Allow"))
+	})
+
+	assert.Equal(t, 1, loads)
+}
+
+func TestHandler_Redirect(t *testing.T) {
+	store := &fcache.StoreMock{
+		MetaFunc: func(ctx context.Context, key string) (fcache.FileMeta, error) {
+			return fcache.FileMeta{Key: key}, nil
+		},
+		GetURLFunc: func(ctx context.Context, key string, params fcache.GetURLParams) (string, error) {
+			assert.Equal(t, time.Minute, params.Expires)
+			return "https://s3.example.com/" + key + "?signature=abc", nil
+		},
+	}
+	svc := fcache.NewLoadingCache(store, fcache.WithLogger(fcache.NopLogger()))
+	h := NewHandler(svc, PathResolver("/", time.Hour, nil), WithRedirect(time.Minute))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/key", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://s3.example.com/key?signature=abc", rec.Header().Get("Location"))
+
+	t.Run("urls are not supported", func(t *testing.T) {
+		svc := fcache.NewLoadingCache(fcache.NewMemory(0, fcache.NopLogger()), fcache.WithLogger(fcache.NopLogger()))
+		require.NoError(t, svc.Set(context.Background(), "key", time.Hour, fcache.FileMeta{},
+			io.NopCloser(strings.NewReader("content"))))
+		h := NewHandler(svc, PathResolver("/", time.Hour, nil), WithRedirect(time.Minute))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/key", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "content", rec.Body.String())
+	})
+
+	t.Run("local urls", func(t *testing.T) {
+		store, err := fcache.NewFS(t.TempDir(), fcache.NopLogger())
+		require.NoError(t, err)
+		svc := fcache.NewLoadingCache(store, fcache.WithLogger(fcache.NopLogger()))
+		require.NoError(t, svc.Set(context.Background(), "key", time.Hour, fcache.FileMeta{},
+			io.NopCloser(strings.NewReader("content"))))
+		h := NewHandler(svc, PathResolver("/", time.Hour, nil), WithRedirect(time.Minute))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/key", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Location"))
+		assert.Equal(t, "content", rec.Body.String())
+	})
+}
+
+func TestHandler_NotSeekable(t *testing.T) {
+	createdAt := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	store := &fcache.StoreMock{
+		MetaFunc: func(ctx context.Context, key string) (fcache.FileMeta, error) {
+			return fcache.FileMeta{Key: key, Size: 7, CreatedAt: createdAt}, nil
+		},
+		GetFunc: func(ctx context.Context, key string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("content")), nil
+		},
+	}
+	svc := fcache.NewLoadingCache(store, fcache.WithLogger(fcache.NopLogger()))
+	h := NewHandler(svc, PathResolver("/", time.Hour, nil))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/key", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "content", rec.Body.String())
+	assert.Equal(t, "7", rec.Header().Get("Content-Length"))
+
+	req := httptest.NewRequest(http.MethodGet, "/key", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/key", nil)
+	req.Header.Set("If-Modified-Since", createdAt.Format(http.TimeFormat))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestHandler_NoLoader(t *testing.T) {
+	store := fcache.NewMemory(0, fcache.NopLogger())
+	svc := fcache.NewLoadingCache(store, fcache.WithLogger(fcache.NopLogger()))
+	h := NewHandler(svc, PathResolver("/", time.Hour, nil), WithLogger(fcache.NopLogger()))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	require.NoError(t, store.Put(context.Background(), "present", fcache.FileMeta{}, io.NopCloser(strings.NewReader("data"))))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/present", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "data", rec.Body.String())
+}
@@ -0,0 +1,406 @@
+package fcachehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Semior001/fcache"
+)
+
+const (
+	metaStatusKey       = "_http_status"
+	metaHeaderKeyPrefix = "_http_header_"
+)
+
+// headers, which are not stored along with the response
+var skippedHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Set-Cookie":          true,
+	"Content-Length":      true,
+}
+
+// Transport is an http.RoundTripper, which keeps responses to GET requests
+// in the cache. The key of the response is the request's URL along with
+// the values of the configured Vary headers. The response's status and
+// headers are kept in the file's meta, and its TTL is derived from
+// Cache-Control and Expires headers. Expired responses with ETag or
+// Last-Modified headers are revalidated with conditional requests.
+// Requests with Range, Authorization or "Cache-Control: no-store"
+// headers and responses with statuses other than 200 or with
+// "Cache-Control: no-store" or "private" are not cached. If the store
+// fails, the response is requested from the origin and served uncached.
+type Transport struct {
+	cache      *fcache.LoadingCache
+	next       http.RoundTripper
+	vary       []string
+	defaultTTL time.Duration
+	now        func() time.Time
+}
+
+// TransportOption is a function to apply options of Transport.
+type TransportOption func(t *Transport)
+
+// WithNext sets the transport to make requests with.
+// http.DefaultTransport is used by default.
+func WithNext(next http.RoundTripper) TransportOption {
+	return func(t *Transport) { t.next = next }
+}
+
+// WithVary sets request headers, which values are included into the key
+// of the response.
+func WithVary(headers ...string) TransportOption {
+	return func(t *Transport) {
+		for _, h := range headers {
+			t.vary = append(t.vary, http.CanonicalHeaderKey(h))
+		}
+		sort.Strings(t.vary)
+	}
+}
+
+// WithDefaultTTL sets the TTL of responses without Cache-Control and Expires
+// headers. Such responses are revalidated on each request by default.
+func WithDefaultTTL(ttl time.Duration) TransportOption {
+	return func(t *Transport) { t.defaultTTL = ttl }
+}
+
+// NewTransport makes new instance of Transport.
+func NewTransport(cache *fcache.LoadingCache, opts ...TransportOption) *Transport {
+	res := &Transport{cache: cache, next: http.DefaultTransport, now: time.Now}
+
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	return res
+}
+
+// uncacheableResponse is returned by the loader, when the response must not
+// be cached.
+type uncacheableResponse struct {
+	resp  *http.Response
+	owner *byte
+}
+
+func (e *uncacheableResponse) Error() string {
+	return fmt.Sprintf("response with status %d is not cacheable", e.resp.StatusCode)
+}
+
+func (e *uncacheableResponse) Unwrap() error { return fcache.ErrUncacheable }
+
+// originError is returned by the loader, when the request to the origin
+// has failed.
+type originError struct{ err error }
+
+func (e *originError) Error() string { return e.err.Error() }
+
+func (e *originError) Unwrap() error { return e.err }
+
+// notModified is returned by the loader, when the stored response has been
+// revalidated and its meta has been updated in place.
+type notModified struct{ meta fcache.FileMeta }
+
+func (e *notModified) Error() string { return "stored response is not modified" }
+
+func (e *notModified) Unwrap() error { return fcache.ErrUncacheable }
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheableRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	key := t.key(req)
+
+	// loader may be shared with concurrent requests, owner distinguishes
+	// the response, fetched by this one
+	owner := new(byte)
+	rd, meta, err := t.cache.GetFile(req.Context(), fcache.GetRequest{
+		Key: key,
+		Loader: func(ctx context.Context) (io.ReadCloser, fcache.FileMeta, error) {
+			return t.load(ctx, req, key, owner)
+		},
+	})
+
+	var uerr *uncacheableResponse
+	var nerr *notModified
+	var oerr *originError
+	switch {
+	case errors.As(err, &uerr) && uerr.owner == owner:
+		return uerr.resp, nil
+	case errors.As(err, &uerr):
+		// concurrent request received uncacheable response, making our own
+		return t.next.RoundTrip(req)
+	case errors.As(err, &nerr):
+		if rd, err = t.cache.Store.Get(req.Context(), key); err != nil {
+			// revalidated response is lost, requesting it again
+			return t.next.RoundTrip(req)
+		}
+		return response(req, rd, nerr.meta), nil
+	case errors.As(err, &oerr):
+		return nil, fmt.Errorf("get response from cache: %w", err)
+	case err != nil:
+		// store has failed, the response is served from the origin uncached
+		return t.next.RoundTrip(req)
+	}
+
+	return response(req, rd, meta), nil
+}
+
+// load requests the resource, revalidating the expired response, if it
+// is still in the store.
+func (t *Transport) load(
+	ctx context.Context,
+	req *http.Request,
+	key string,
+	owner *byte,
+) (io.ReadCloser, fcache.FileMeta, error) {
+	outReq := req.Clone(ctx)
+
+	stale, err := t.cache.Store.Meta(ctx, key)
+	if err != nil {
+		stale = fcache.FileMeta{}
+	}
+	if stale.Meta != nil {
+		if etag := header(stale, "Etag"); etag != "" {
+			outReq.Header.Set("If-None-Match", etag)
+		}
+		if lm := header(stale, "Last-Modified"); lm != "" {
+			outReq.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(outReq)
+	if err != nil {
+		return nil, fcache.FileMeta{}, &originError{err: err}
+	}
+
+	if resp.StatusCode == http.StatusNotModified && stale.Meta != nil {
+		return t.revalidated(ctx, resp, key, stale)
+	}
+
+	ttl, ok := t.ttl(resp.Header)
+	if resp.StatusCode != http.StatusOK || !ok {
+		return nil, fcache.FileMeta{}, &uncacheableResponse{resp: resp, owner: owner}
+	}
+
+	meta := fcache.FileMeta{
+		Mime: resp.Header.Get("Content-Type"),
+		Size: resp.ContentLength,
+		Meta: map[string]string{metaStatusKey: strconv.Itoa(resp.StatusCode)},
+	}
+	if meta.Size < 0 {
+		meta.Size = 0
+	}
+	setHeaderMeta(meta, resp.Header)
+
+	return resp.Body, meta.WithExpiration(t.now().Add(ttl)), nil
+}
+
+// revalidated updates the expiration and headers of the stored response
+// from the 304 response, keeping its content in place.
+func (t *Transport) revalidated(
+	ctx context.Context,
+	resp *http.Response,
+	key string,
+	stale fcache.FileMeta,
+) (io.ReadCloser, fcache.FileMeta, error) {
+	_ = resp.Body.Close()
+
+	// the stale content is served anyway, even if the server now forbids
+	// to store it, so it is kept till the next request only
+	ttl, _ := t.ttl(resp.Header)
+
+	meta := stale.WithExpiration(t.now().Add(ttl))
+	for k := range resp.Header {
+		if skippedHeaders[k] {
+			continue
+		}
+		// stores may have changed the case of the header's key
+		for mk := range meta.Meta {
+			if strings.EqualFold(mk, metaHeaderKeyPrefix+k) {
+				delete(meta.Meta, mk)
+			}
+		}
+	}
+	setHeaderMeta(meta, resp.Header)
+
+	if err := t.cache.Store.UpdateMeta(ctx, key, meta); err != nil {
+		return nil, fcache.FileMeta{}, fmt.Errorf("update meta of revalidated response: %w", err)
+	}
+
+	return nil, fcache.FileMeta{}, &notModified{meta: meta}
+}
+
+// ttl returns the TTL of the response, as set by its headers.
+// It reports false, if the response must not be cached.
+func (t *Transport) ttl(h http.Header) (time.Duration, bool) {
+	cc := parseCacheControl(h.Get("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["private"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["no-cache"]; ok {
+		return 0, true
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[directive]; ok {
+			secs, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || secs < 0 {
+				return 0, true
+			}
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0, true // invalid date means "already expired"
+		}
+		date := t.now()
+		if d, err := http.ParseTime(h.Get("Date")); err == nil {
+			date = d
+		}
+		if ttl := expires.Sub(date); ttl > 0 {
+			return ttl, true
+		}
+		return 0, true
+	}
+
+	return t.defaultTTL, true
+}
+
+// key returns the key of the response to the request.
+func (t *Transport) key(req *http.Request) string {
+	key := req.URL.String()
+	for _, h := range t.vary {
+		key += "|" + h + "=" + strings.Join(req.Header.Values(h), ",")
+	}
+	return key
+}
+
+// cacheableRequest reports whether the response to the request may be
+// taken from the cache.
+func cacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || req.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, noStore := parseCacheControl(req.Header.Get("Cache-Control"))["no-store"]
+	return !noStore
+}
+
+// response makes the response from the cached file.
+func response(req *http.Request, rd io.ReadCloser, meta fcache.FileMeta) *http.Response {
+	status, err := strconv.Atoi(metaValue(meta, metaStatusKey))
+	if err != nil {
+		status = http.StatusOK
+	}
+
+	h := http.Header{}
+	for k, v := range meta.Meta {
+		if strings.HasPrefix(strings.ToLower(k), metaHeaderKeyPrefix) {
+			h[http.CanonicalHeaderKey(k[len(metaHeaderKeyPrefix):])] = decodeHeader(v)
+		}
+	}
+
+	contentLength := int64(-1)
+	if meta.Size > 0 {
+		contentLength = meta.Size
+		h.Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          rd,
+		ContentLength: contentLength,
+		Request:       req,
+	}
+}
+
+// setHeaderMeta puts the response's headers into the file's meta.
+func setHeaderMeta(meta fcache.FileMeta, h http.Header) {
+	for k, v := range h {
+		if skippedHeaders[k] {
+			continue
+		}
+		meta.Meta[metaHeaderKeyPrefix+strings.ToLower(k)] = encodeHeader(v)
+	}
+}
+
+// header returns the value of the stored response's header.
+func header(meta fcache.FileMeta, name string) string {
+	return decodeHeader(metaValue(meta, metaHeaderKeyPrefix+name))[0]
+}
+
+// encodeHeader encodes values of the header into the single meta value.
+// Values are percent-encoded and joined with commas, as some stores, like
+// S3, keep meta in HTTP headers, which can't contain line breaks.
+func encodeHeader(values []string) string {
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = url.QueryEscape(v)
+	}
+	return strings.Join(res, ",")
+}
+
+// decodeHeader decodes values of the header, encoded by encodeHeader.
+func decodeHeader(v string) []string {
+	res := strings.Split(v, ",")
+	for i, part := range res {
+		if dv, err := url.QueryUnescape(part); err == nil {
+			res[i] = dv
+		}
+	}
+	return res
+}
+
+// metaValue returns the value from the file's meta by the case-insensitive
+// key, as some stores don't preserve the case of keys.
+func metaValue(meta fcache.FileMeta, key string) string {
+	for k, v := range meta.Meta {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseCacheControl parses directives of the Cache-Control header.
+func parseCacheControl(v string) map[string]string {
+	res := map[string]string{}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, val = part[:i], strings.Trim(part[i+1:], `"`)
+		}
+		res[strings.ToLower(strings.TrimSpace(name))] = val
+	}
+	return res
+}
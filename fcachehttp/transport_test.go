@@ -0,0 +1,267 @@
+package fcachehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/Semior001/fcache/fakes3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	var calls, fullResponses, puts, errs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/cached":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Type", "text/plain")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/not-found":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "not found")
+			return
+		case "/revalidated":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = io.WriteString(w, r.Header.Get("Accept-Language"))
+			return
+		}
+		atomic.AddInt32(&fullResponses, 1)
+		_, _ = io.WriteString(w, "body of "+r.URL.Path)
+	}))
+	defer ts.Close()
+
+	prepare := func() *http.Client {
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&fullResponses, 0)
+		atomic.StoreInt32(&puts, 0)
+		atomic.StoreInt32(&errs, 0)
+		svc := fcache.NewLoadingCache(fcache.NewMemory(0, fcache.NopLogger()),
+			fcache.WithLogger(fcache.NopLogger()),
+			fcache.WithHooks(fcache.HookFuncs{
+				Put:   func(context.Context, string, fcache.FileMeta) { atomic.AddInt32(&puts, 1) },
+				Error: func(context.Context, string, fcache.FileMeta, fcache.Op, error) { atomic.AddInt32(&errs, 1) },
+			}),
+		)
+		return &http.Client{Transport: NewTransport(svc, WithVary("Accept-Language"))}
+	}
+
+	get := func(t *testing.T, cl *http.Client, path string, headers map[string]string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := cl.Do(req)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp, string(b)
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		cl := prepare()
+		for i := 0; i < 3; i++ {
+			resp, body := get(t, cl, "/cached", nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "body of /cached", body)
+			assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+			assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "max-age=60", resp.Header.Get("Cache-Control"))
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("no-store", func(t *testing.T) {
+		cl := prepare()
+		for i := 0; i < 2; i++ {
+			resp, body := get(t, cl, "/no-store", nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "body of /no-store", body)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.Zero(t, atomic.LoadInt32(&puts))
+		assert.Zero(t, atomic.LoadInt32(&errs))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		cl := prepare()
+		for i := 0; i < 2; i++ {
+			resp, body := get(t, cl, "/not-found", nil)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+			assert.Equal(t, "not found", body)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.Zero(t, atomic.LoadInt32(&puts))
+		assert.Zero(t, atomic.LoadInt32(&errs))
+	})
+
+	t.Run("revalidated", func(t *testing.T) {
+		cl := prepare()
+		for i := 0; i < 3; i++ {
+			resp, body := get(t, cl, "/revalidated", nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "body of /revalidated", body)
+			assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+		}
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fullResponses))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&puts), "revalidated response must not be put again")
+		assert.Zero(t, atomic.LoadInt32(&errs))
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		cl := prepare()
+		for _, lang := range []string{"en", "de", "en"} {
+			_, body := get(t, cl, "/vary", map[string]string{"Accept-Language": lang})
+			assert.Equal(t, lang, body)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("bypass", func(t *testing.T) {
+		cl := prepare()
+		for i := 0; i < 2; i++ {
+			_, body := get(t, cl, "/cached", map[string]string{"Authorization": "Bearer token"})
+			assert.Equal(t, "body of /cached", body)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestTransport_S3(t *testing.T) {
+	var calls int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Add("Link", "</a.css>; rel=preload")
+		w.Header().Add("Link", "</b.js>; rel=preload, </c.js>; rel=preload")
+		_, _ = io.WriteString(w, "body")
+	}))
+	defer origin.Close()
+
+	s3 := httptest.NewServer(fakes3.New("bucket"))
+	defer s3.Close()
+
+	cl, err := minio.New(strings.TrimPrefix(s3.URL, "http://"), &minio.Options{
+		Creds: credentials.NewStaticV4("access-key", "secret-key", ""),
+	})
+	require.NoError(t, err)
+
+	svc := fcache.NewLoadingCache(fcache.NewS3(cl, "bucket", "", fcache.NopLogger()),
+		fcache.WithLogger(fcache.NopLogger()))
+	client := &http.Client{Transport: NewTransport(svc)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(origin.URL)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, "body", string(b))
+		assert.Equal(t, []string{"</a.css>; rel=preload", "</b.js>; rel=preload, </c.js>; rel=preload"},
+			resp.Header.Values("Link"))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestTransport_StoreFailure(t *testing.T) {
+	var calls int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, "body")
+	}))
+	defer origin.Close()
+
+	svc := fcache.NewLoadingCache(failingStore{Memory: fcache.NewMemory(0, fcache.NopLogger())},
+		fcache.WithLogger(fcache.NopLogger()))
+	client := &http.Client{Transport: NewTransport(svc)}
+
+	resp, err := client.Get(origin.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "body", string(b))
+}
+
+// failingStore fails to put files.
+type failingStore struct{ *fcache.Memory }
+
+func (failingStore) Put(_ context.Context, _ string, _ fcache.FileMeta, rd io.ReadCloser) error {
+	_ = rd.Close()
+	return errors.New("store is unavailable")
+}
+
+func TestTransport_ttl(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	tr := NewTransport(nil, WithDefaultTTL(time.Minute))
+	tr.now = func() time.Time { return now }
+
+	tbl := []struct {
+		name      string
+		headers   map[string]string
+		ttl       time.Duration
+		cacheable bool
+	}{
+		{name: "default", ttl: time.Minute, cacheable: true},
+		{name: "max-age", headers: map[string]string{"Cache-Control": "public, max-age=30"}, ttl: 30 * time.Second, cacheable: true},
+		{
+			name:      "s-maxage",
+			headers:   map[string]string{"Cache-Control": "max-age=30, s-maxage=60"},
+			ttl:       time.Minute,
+			cacheable: true,
+		},
+		{name: "no-cache", headers: map[string]string{"Cache-Control": "no-cache"}, cacheable: true},
+		{name: "no-store", headers: map[string]string{"Cache-Control": "no-store"}},
+		{name: "private", headers: map[string]string{"Cache-Control": "private, max-age=30"}},
+		{
+			name: "expires",
+			headers: map[string]string{
+				"Date":    now.Add(-time.Minute).Format(http.TimeFormat),
+				"Expires": now.Add(time.Hour).Format(http.TimeFormat),
+			},
+			ttl:       time.Hour + time.Minute,
+			cacheable: true,
+		},
+		{name: "expires without date", headers: map[string]string{"Expires": now.Add(time.Hour).Format(http.TimeFormat)}, ttl: time.Hour, cacheable: true},
+		{name: "expired", headers: map[string]string{"Expires": "0"}, cacheable: true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			ttl, ok := tr.ttl(h)
+			assert.Equal(t, tt.cacheable, ok)
+			assert.Equal(t, tt.ttl, ttl)
+		})
+	}
+}
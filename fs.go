@@ -0,0 +1,363 @@
+package fcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fsDataExt    = ".data"
+	fsMetaExt    = ".meta"
+	fsTmpPattern = ".fcache-tmp-*"
+)
+
+// FS implements Store on the local filesystem.
+// Each file is kept in the root directory as a pair of files: the content
+// itself and its JSON-encoded metadata. Both are written to temporary files
+// first and then renamed, so readers never observe partially written files.
+type FS struct {
+	log Logger
+	dir string
+
+	// mu guards the consistency of content-metadata pairs
+	mu sync.RWMutex
+
+	// mockable fields
+	now func() time.Time
+}
+
+// fsMeta is the on-disk representation of FileMeta.
+type fsMeta struct {
+	Key       string            `json:"key"`
+	Name      string            `json:"name,omitempty"`
+	Mime      string            `json:"mime,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Size      int64             `json:"size"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (m fsMeta) fileMeta() FileMeta {
+	res := FileMeta{
+		Name:      m.Name,
+		Mime:      m.Mime,
+		Meta:      m.Meta,
+		Size:      m.Size,
+		Key:       m.Key,
+		CreatedAt: m.CreatedAt,
+	}
+
+	if res.Meta == nil {
+		res.Meta = map[string]string{}
+	}
+
+	return res
+}
+
+// NewFS makes new instance of FS, creating the root directory, if absent.
+func NewFS(dir string, log Logger) (*FS, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create root directory: %w", err)
+	}
+
+	return &FS{log: log, dir: dir, now: time.Now}, nil
+}
+
+// Meta returns meta information about the file at underlying key.
+func (f *FS) Meta(_ context.Context, key string) (FileMeta, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	m, err := f.readMeta(f.path(key, fsMetaExt))
+	if err != nil {
+		return FileMeta{}, err
+	}
+
+	return m.fileMeta(), nil
+}
+
+// UpdateMeta updates meta information about the file at underlying key.
+func (f *FS) UpdateMeta(_ context.Context, key string, meta FileMeta) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.readMeta(f.path(key, fsMetaExt))
+	if err != nil {
+		return err
+	}
+
+	current.Name = meta.Name
+	current.Mime = meta.Mime
+	current.Meta = meta.Meta
+
+	tmp, err := f.writeMeta(current)
+	if err != nil {
+		return fmt.Errorf("write meta: %w", err)
+	}
+
+	if err = os.Rename(tmp, f.path(key, fsMetaExt)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("replace meta file: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the reader of the file's content.
+func (f *FS) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if _, err := os.Stat(f.path(key, fsMetaExt)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("stat meta file: %w", err)
+	}
+
+	file, err := os.Open(f.path(key, fsDataExt))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("open data file: %w", err)
+	}
+
+	return file, nil
+}
+
+// GetRange returns the reader of the file's part.
+func (f *FS) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	rd, err := f.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	file := rd.(*os.File)
+	return struct {
+		io.Reader
+		io.Closer
+	}{Reader: sectionReader(file, offset, length), Closer: file}, nil
+}
+
+// GetURL returns the "file://" URL of the file's content.
+// Params are ignored, as local files can't be signed.
+func (f *FS) GetURL(ctx context.Context, key string, _ GetURLParams) (string, error) {
+	if _, err := f.Meta(ctx, key); err != nil {
+		return "", err
+	}
+
+	path, err := filepath.Abs(f.path(key, fsDataExt))
+	if err != nil {
+		return "", fmt.Errorf("make absolute path: %w", err)
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(), nil
+}
+
+// Put puts file into the directory, replacing the previous one, if any.
+func (f *FS) Put(_ context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+	defer func() {
+		if err := rd.Close(); err != nil {
+			f.log.Printf("[WARN] failed to close reader: %v", err)
+		}
+	}()
+
+	dataTmp, size, err := f.writeData(rd)
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+
+	metaTmp, err := f.writeMeta(fsMeta{
+		Key:       key,
+		Name:      meta.Name,
+		Mime:      meta.Mime,
+		Meta:      meta.Meta,
+		Size:      size,
+		CreatedAt: f.now(),
+	})
+	if err != nil {
+		_ = os.Remove(dataTmp)
+		return fmt.Errorf("write meta: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err = os.Rename(dataTmp, f.path(key, fsDataExt)); err != nil {
+		_ = os.Remove(dataTmp)
+		_ = os.Remove(metaTmp)
+		return fmt.Errorf("replace data file: %w", err)
+	}
+
+	if err = os.Rename(metaTmp, f.path(key, fsMetaExt)); err != nil {
+		// previous data is replaced already, so the file is removed
+		// completely, not to leave the previous meta with the new data
+		_ = os.Remove(metaTmp)
+		_ = os.Remove(f.path(key, fsMetaExt))
+		_ = os.Remove(f.path(key, fsDataExt))
+		return fmt.Errorf("replace meta file: %w", err)
+	}
+
+	return nil
+}
+
+// Remove removes file by its key.
+func (f *FS) Remove(_ context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.path(key, fsMetaExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("remove meta file: %w", err)
+	}
+
+	if err = os.Remove(f.path(key, fsDataExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove data file: %w", err)
+	}
+
+	return nil
+}
+
+// Stat returns store stats.
+func (f *FS) Stat(ctx context.Context) (res StoreStats, err error) {
+	files, err := f.List(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	for _, file := range files {
+		res.Keys++
+		res.Size += file.Size
+	}
+
+	return res, nil
+}
+
+// Keys returns all keys, present in store.
+func (f *FS) Keys(ctx context.Context) ([]string, error) {
+	files, err := f.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(files))
+	for _, file := range files {
+		res = append(res, file.Key)
+	}
+
+	return res, nil
+}
+
+// List lists all files in the directory.
+func (f *FS) List(ctx context.Context) ([]FileMeta, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+
+	var res []FileMeta
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fsMetaExt) {
+			continue
+		}
+
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		m, err := f.readMeta(filepath.Join(f.dir, entry.Name()))
+		if errors.Is(err, ErrNotFound) {
+			continue // removed concurrently
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read meta file %q: %w", entry.Name(), err)
+		}
+
+		res = append(res, m.fileMeta())
+	}
+
+	return res, nil
+}
+
+func (f *FS) readMeta(path string) (fsMeta, error) {
+	bts, err := os.ReadFile(path) //nolint:gosec // path is built from the key hash
+	if errors.Is(err, os.ErrNotExist) {
+		return fsMeta{}, ErrNotFound
+	}
+	if err != nil {
+		return fsMeta{}, fmt.Errorf("read meta file: %w", err)
+	}
+
+	var m fsMeta
+	if err = json.Unmarshal(bts, &m); err != nil {
+		return fsMeta{}, fmt.Errorf("unmarshal meta: %w", err)
+	}
+
+	return m, nil
+}
+
+// writeMeta writes meta into a temporary file and returns its path.
+func (f *FS) writeMeta(m fsMeta) (string, error) {
+	bts, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("marshal meta: %w", err)
+	}
+
+	path, _, err := f.writeData(bytes.NewReader(bts))
+	return path, err
+}
+
+// writeData writes the content of the reader into a temporary file and
+// returns its path along with the amount of written bytes.
+func (f *FS) writeData(rd io.Reader) (path string, n int64, err error) {
+	tmp, err := os.CreateTemp(f.dir, fsTmpPattern)
+	if err != nil {
+		return "", 0, fmt.Errorf("create temp file: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if n, err = io.Copy(tmp, rd); err != nil {
+		return "", 0, fmt.Errorf("copy content: %w", err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return "", 0, fmt.Errorf("sync temp file: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("close temp file: %w", err)
+	}
+
+	return tmp.Name(), n, nil
+}
+
+// path returns the path to the file with the given extension for the key.
+// Keys are hashed to be safe for use as file names.
+func (f *FS) path(key, ext string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+ext)
+}
@@ -0,0 +1,178 @@
+package fcache
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS_PutGet(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+		svc := newTestFS(t, now)
+
+		err := svc.Put(context.Background(), "key", FileMeta{
+			Name: "a.txt",
+			Mime: "text/plain",
+			Meta: map[string]string{metaInvalidateAtKey: "some time"},
+		}, io.NopCloser(strings.NewReader("some file data")))
+		require.NoError(t, err)
+
+		meta, err := svc.Meta(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, FileMeta{
+			Name:      "a.txt",
+			Mime:      "text/plain",
+			Meta:      map[string]string{metaInvalidateAtKey: "some time"},
+			Size:      14,
+			Key:       "key",
+			CreatedAt: now,
+		}, meta)
+
+		rd, err := svc.Get(context.Background(), "key")
+		require.NoError(t, err)
+		bts, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+		assert.Equal(t, []byte("some file data"), bts)
+
+		entries, err := os.ReadDir(svc.dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "temp files must not be left")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		svc := newTestFS(t, time.Now())
+
+		_, err := svc.Meta(context.Background(), "key")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = svc.Get(context.Background(), "key")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = svc.GetURL(context.Background(), "key", GetURLParams{})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = svc.UpdateMeta(context.Background(), "key", FileMeta{})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = svc.Remove(context.Background(), "key")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("failed to replace meta", func(t *testing.T) {
+		svc := newTestFS(t, time.Now())
+
+		// meta file can't be replaced by the directory
+		require.NoError(t, os.MkdirAll(filepath.Join(svc.path("key", fsMetaExt), "dir"), 0o700))
+
+		err := svc.Put(context.Background(), "key", FileMeta{}, io.NopCloser(strings.NewReader("some file data")))
+		assert.Error(t, err)
+
+		_, err = os.Stat(svc.path("key", fsDataExt))
+		assert.ErrorIs(t, err, os.ErrNotExist, "data file must be removed")
+
+		entries, err := os.ReadDir(svc.dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temp files must not be left")
+	})
+}
+
+func TestFS_UpdateMeta(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	svc := newTestFS(t, now)
+
+	err := svc.Put(context.Background(), "key", FileMeta{Name: "a.txt", Mime: "text/plain"},
+		io.NopCloser(strings.NewReader("some file data")))
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return now.Add(time.Hour) }
+	err = svc.UpdateMeta(context.Background(), "key", FileMeta{
+		Name: "b.txt",
+		Mime: "text/plain",
+		Meta: map[string]string{"k": "v"},
+		Size: 100500,
+	})
+	require.NoError(t, err)
+
+	meta, err := svc.Meta(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, FileMeta{
+		Name:      "b.txt",
+		Mime:      "text/plain",
+		Meta:      map[string]string{"k": "v"},
+		Size:      14,
+		Key:       "key",
+		CreatedAt: now,
+	}, meta)
+}
+
+func TestFS_GetURL(t *testing.T) {
+	svc := newTestFS(t, time.Now())
+
+	err := svc.Put(context.Background(), "key", FileMeta{}, io.NopCloser(strings.NewReader("some file data")))
+	require.NoError(t, err)
+
+	u, err := svc.GetURL(context.Background(), "key", GetURLParams{})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u, "file://"))
+	assert.True(t, strings.HasSuffix(u, fsDataExt))
+}
+
+func TestFS_Remove(t *testing.T) {
+	svc := newTestFS(t, time.Now())
+
+	err := svc.Put(context.Background(), "key", FileMeta{}, io.NopCloser(strings.NewReader("some file data")))
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Remove(context.Background(), "key"))
+
+	_, err = svc.Meta(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	entries, err := os.ReadDir(svc.dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFS_ListKeysStat(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	svc := newTestFS(t, now)
+
+	err := svc.Put(context.Background(), "key-1", FileMeta{Name: "a.txt"}, io.NopCloser(strings.NewReader("12345")))
+	require.NoError(t, err)
+	err = svc.Put(context.Background(), "some/key-2", FileMeta{Name: "b.txt"}, io.NopCloser(strings.NewReader("123")))
+	require.NoError(t, err)
+
+	files, err := svc.List(context.Background())
+	require.NoError(t, err)
+	sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	assert.Equal(t, []FileMeta{
+		{Name: "a.txt", Meta: map[string]string{}, Size: 5, Key: "key-1", CreatedAt: now},
+		{Name: "b.txt", Meta: map[string]string{}, Size: 3, Key: "some/key-2", CreatedAt: now},
+	}, files)
+
+	keys, err := svc.Keys(context.Background())
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"key-1", "some/key-2"}, keys)
+
+	stat, err := svc.Stat(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, StoreStats{Keys: 2, Size: 8}, stat)
+}
+
+func newTestFS(t *testing.T, now time.Time) *FS {
+	svc, err := NewFS(t.TempDir(), NopLogger())
+	require.NoError(t, err)
+	svc.now = func() time.Time { return now }
+	return svc
+}
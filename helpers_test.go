@@ -0,0 +1,15 @@
+package fcache
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+func stringLoader(s string) Loader {
+	return func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+		return io.NopCloser(strings.NewReader(s)), FileMeta{Size: int64(len(s))}, nil
+	}
+}
+
+func stringReader(s string) io.ReadCloser { return io.NopCloser(strings.NewReader(s)) }
@@ -0,0 +1,266 @@
+package fcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RemoveReason describes why the file has been removed from the cache.
+type RemoveReason string
+
+// Reasons of the file's removal.
+const (
+	RemoveExpired     RemoveReason = "expired"     // TTL of the file has passed
+	RemoveEvicted     RemoveReason = "evicted"     // cache exceeded its size limits
+	RemoveDeleted     RemoveReason = "deleted"     // LoadingCache.Delete call
+	RemoveInvalidated RemoveReason = "invalidated" // invalidation by tag or prefix
+)
+
+// Hooks receives events of the cache lifecycle. Each callback receives the
+// key and the meta of the file, as far as it is known at the moment.
+// Callbacks are called synchronously, in the caller's goroutine, thus they
+// must be fast, use AsyncHooks to deliver events in background.
+// Panics in callbacks are recovered and logged.
+type Hooks interface {
+	// OnHit is called, when the file is served from the cache.
+	OnHit(ctx context.Context, key string, meta FileMeta)
+	// OnMiss is called, when the file is absent and is about to be loaded.
+	OnMiss(ctx context.Context, key string, meta FileMeta)
+	// OnLoadStart is called before the loader's call.
+	OnLoadStart(ctx context.Context, key string, meta FileMeta)
+	// OnLoadFinish is called with the loader's result.
+	OnLoadFinish(ctx context.Context, key string, meta FileMeta, err error)
+	// OnPut is called, when the file has been put into the store.
+	OnPut(ctx context.Context, key string, meta FileMeta)
+	// OnExtendTTL is called, when the file's TTL has been updated.
+	OnExtendTTL(ctx context.Context, key string, meta FileMeta)
+	// OnRemove is called, when the file has been removed from the store.
+	OnRemove(ctx context.Context, key string, meta FileMeta, reason RemoveReason)
+	// OnError is called, when the operation on the file fails.
+	OnError(ctx context.Context, key string, meta FileMeta, op Op, err error)
+}
+
+// HookFuncs implements Hooks with optional functions.
+// Nil functions are skipped.
+type HookFuncs struct {
+	Hit        func(ctx context.Context, key string, meta FileMeta)
+	Miss       func(ctx context.Context, key string, meta FileMeta)
+	LoadStart  func(ctx context.Context, key string, meta FileMeta)
+	LoadFinish func(ctx context.Context, key string, meta FileMeta, err error)
+	Put        func(ctx context.Context, key string, meta FileMeta)
+	ExtendTTL  func(ctx context.Context, key string, meta FileMeta)
+	Remove     func(ctx context.Context, key string, meta FileMeta, reason RemoveReason)
+	Error      func(ctx context.Context, key string, meta FileMeta, op Op, err error)
+}
+
+// OnHit implements Hooks.
+func (h HookFuncs) OnHit(ctx context.Context, key string, meta FileMeta) {
+	if h.Hit != nil {
+		h.Hit(ctx, key, meta)
+	}
+}
+
+// OnMiss implements Hooks.
+func (h HookFuncs) OnMiss(ctx context.Context, key string, meta FileMeta) {
+	if h.Miss != nil {
+		h.Miss(ctx, key, meta)
+	}
+}
+
+// OnLoadStart implements Hooks.
+func (h HookFuncs) OnLoadStart(ctx context.Context, key string, meta FileMeta) {
+	if h.LoadStart != nil {
+		h.LoadStart(ctx, key, meta)
+	}
+}
+
+// OnLoadFinish implements Hooks.
+func (h HookFuncs) OnLoadFinish(ctx context.Context, key string, meta FileMeta, err error) {
+	if h.LoadFinish != nil {
+		h.LoadFinish(ctx, key, meta, err)
+	}
+}
+
+// OnPut implements Hooks.
+func (h HookFuncs) OnPut(ctx context.Context, key string, meta FileMeta) {
+	if h.Put != nil {
+		h.Put(ctx, key, meta)
+	}
+}
+
+// OnExtendTTL implements Hooks.
+func (h HookFuncs) OnExtendTTL(ctx context.Context, key string, meta FileMeta) {
+	if h.ExtendTTL != nil {
+		h.ExtendTTL(ctx, key, meta)
+	}
+}
+
+// OnRemove implements Hooks.
+func (h HookFuncs) OnRemove(ctx context.Context, key string, meta FileMeta, reason RemoveReason) {
+	if h.Remove != nil {
+		h.Remove(ctx, key, meta, reason)
+	}
+}
+
+// OnError implements Hooks.
+func (h HookFuncs) OnError(ctx context.Context, key string, meta FileMeta, op Op, err error) {
+	if h.Error != nil {
+		h.Error(ctx, key, meta, op, err)
+	}
+}
+
+// hook calls fn with the cache's hooks, if set, recovering its panic.
+// The meta, passed to hooks, is a copy of the given one.
+func (l *LoadingCache) hook(ctx context.Context, meta FileMeta, fn func(h Hooks, meta FileMeta)) {
+	if l.Hooks == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			l.log(ctx, LevelError, "hook panicked", Attr{Key: "panic", Value: r})
+		}
+	}()
+
+	fn(l.Hooks, copyMeta(meta))
+}
+
+// stored records the access to the put file and reports it to hooks.
+func (l *LoadingCache) stored(ctx context.Context, key string, meta FileMeta) {
+	l.touch(key, true)
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnPut(ctx, key, meta) })
+}
+
+// removed reports the removal of the file to hooks.
+func (l *LoadingCache) removed(ctx context.Context, key string, meta FileMeta, reason RemoveReason) {
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnRemove(ctx, key, meta, reason) })
+}
+
+// AsyncHooks delivers events to the wrapped hooks in a background goroutine.
+// Events are dropped, when the buffer is full. Contexts, passed to the
+// wrapped hooks, keep values of the original ones, but are never canceled.
+type AsyncHooks struct {
+	hooks   Hooks
+	log     Logger
+	events  chan func()
+	dropped int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewAsyncHooks makes new instance of AsyncHooks with the given buffer size
+// and starts the delivery. Close must be called to stop it.
+func NewAsyncHooks(hooks Hooks, buffer int, log Logger) *AsyncHooks {
+	res := &AsyncHooks{
+		hooks:  hooks,
+		log:    log,
+		events: make(chan func(), buffer),
+		done:   make(chan struct{}),
+	}
+
+	go res.run()
+
+	return res
+}
+
+// Dropped returns the number of events, dropped due to the full buffer.
+func (a *AsyncHooks) Dropped() int64 { return atomic.LoadInt64(&a.dropped) }
+
+// Close stops the delivery, waiting for the buffered events to be delivered.
+// Events, sent after Close, are dropped.
+func (a *AsyncHooks) Close() {
+	a.closeOnce.Do(func() { close(a.events) })
+	<-a.done
+}
+
+func (a *AsyncHooks) run() {
+	defer close(a.done)
+	for fn := range a.events {
+		a.deliver(fn)
+	}
+}
+
+func (a *AsyncHooks) deliver(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.log.Printf("[ERROR] hook panicked: %v", r)
+		}
+	}()
+	fn()
+}
+
+func (a *AsyncHooks) send(fn func()) {
+	defer func() {
+		// events channel is closed
+		if recover() != nil {
+			atomic.AddInt64(&a.dropped, 1)
+		}
+	}()
+
+	select {
+	case a.events <- fn:
+	default:
+		atomic.AddInt64(&a.dropped, 1)
+	}
+}
+
+// OnHit implements Hooks.
+func (a *AsyncHooks) OnHit(ctx context.Context, key string, meta FileMeta) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnHit(ctx, key, meta) })
+}
+
+// OnMiss implements Hooks.
+func (a *AsyncHooks) OnMiss(ctx context.Context, key string, meta FileMeta) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnMiss(ctx, key, meta) })
+}
+
+// OnLoadStart implements Hooks.
+func (a *AsyncHooks) OnLoadStart(ctx context.Context, key string, meta FileMeta) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnLoadStart(ctx, key, meta) })
+}
+
+// OnLoadFinish implements Hooks.
+func (a *AsyncHooks) OnLoadFinish(ctx context.Context, key string, meta FileMeta, err error) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnLoadFinish(ctx, key, meta, err) })
+}
+
+// OnPut implements Hooks.
+func (a *AsyncHooks) OnPut(ctx context.Context, key string, meta FileMeta) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnPut(ctx, key, meta) })
+}
+
+// OnExtendTTL implements Hooks.
+func (a *AsyncHooks) OnExtendTTL(ctx context.Context, key string, meta FileMeta) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnExtendTTL(ctx, key, meta) })
+}
+
+// OnRemove implements Hooks.
+func (a *AsyncHooks) OnRemove(ctx context.Context, key string, meta FileMeta, reason RemoveReason) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnRemove(ctx, key, meta, reason) })
+}
+
+// OnError implements Hooks.
+func (a *AsyncHooks) OnError(ctx context.Context, key string, meta FileMeta, op Op, err error) {
+	ctx = detach(ctx)
+	a.send(func() { a.hooks.OnError(ctx, key, meta, op, err) })
+}
+
+// detachedContext keeps values of the parent context, but is never canceled.
+type detachedContext struct{ parent context.Context }
+
+func detach(ctx context.Context) context.Context { return detachedContext{parent: ctx} }
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
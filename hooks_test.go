@@ -0,0 +1,123 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_Hooks(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	var events []string
+	record := func(event, key string) { events = append(events, event+":"+key) }
+
+	hooks := HookFuncs{
+		Hit:       func(_ context.Context, key string, _ FileMeta) { record("hit", key) },
+		Miss:      func(_ context.Context, key string, _ FileMeta) { record("miss", key) },
+		LoadStart: func(_ context.Context, key string, _ FileMeta) { record("load_start", key) },
+		LoadFinish: func(_ context.Context, key string, meta FileMeta, err error) {
+			record("load_finish", key)
+			if err == nil {
+				assert.Equal(t, int64(4), meta.Size)
+			}
+		},
+		Put:       func(_ context.Context, key string, _ FileMeta) { record("put", key) },
+		ExtendTTL: func(_ context.Context, key string, _ FileMeta) { record("extend_ttl", key) },
+		Remove: func(_ context.Context, key string, _ FileMeta, reason RemoveReason) {
+			record("remove_"+string(reason), key)
+		},
+		Error: func(_ context.Context, key string, _ FileMeta, op Op, _ error) { record("error_"+string(op), key) },
+	}
+
+	svc := NewLoadingCache(NewMemory(0, NopLogger()),
+		WithLogger(NopLogger()),
+		WithHooks(hooks),
+	)
+	svc.ExtendTTL = true
+	svc.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", TTL: time.Hour, Loader: stringLoader("1234")})
+		require.NoError(t, err)
+		assertReader(t, rd, "1234")
+	}
+
+	_, _, err := svc.GetFile(context.Background(), GetRequest{
+		Key: "failed",
+		Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			return nil, FileMeta{}, errors.New("origin failure")
+		},
+	})
+	require.Error(t, err)
+
+	require.NoError(t, svc.Set(context.Background(), "set", time.Minute, FileMeta{}, stringReader("1234")))
+
+	svc.now = func() time.Time { return now.Add(90 * time.Minute) }
+	_, err = svc.Invalidate(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(context.Background(), "key"))
+
+	assert.Equal(t, []string{
+		"miss:key", "load_start:key", "load_finish:key", "put:key",
+		"hit:key", "extend_ttl:key",
+		"miss:failed", "load_start:failed", "load_finish:failed", "error_load:failed",
+		"put:set",
+		"remove_expired:set",
+		"remove_deleted:key",
+	}, events)
+}
+
+func TestLoadingCache_Hooks_Panic(t *testing.T) {
+	svc := NewLoadingCache(NewMemory(0, NopLogger()),
+		WithLogger(NopLogger()),
+		WithHooks(HookFuncs{Miss: func(context.Context, string, FileMeta) { panic("boom") }}),
+	)
+
+	rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: stringLoader("1234")})
+	require.NoError(t, err)
+	assertReader(t, rd, "1234")
+}
+
+func TestAsyncHooks(t *testing.T) {
+	type key struct{}
+
+	release := make(chan struct{})
+	mu := sync.Mutex{}
+	var hits []string
+
+	async := NewAsyncHooks(HookFuncs{
+		Hit: func(ctx context.Context, k string, meta FileMeta) {
+			<-release
+			assert.NoError(t, ctx.Err())
+			assert.Equal(t, "value", ctx.Value(key{}))
+			if k == "panic" {
+				panic("boom")
+			}
+			mu.Lock()
+			hits = append(hits, k)
+			mu.Unlock()
+		},
+	}, 1, NopLogger())
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	async.OnHit(ctx, "panic", FileMeta{}) // blocks the delivery
+	require.Eventually(t, func() bool { return len(async.events) == 0 }, time.Second, time.Millisecond)
+	async.OnHit(ctx, "key-1", FileMeta{}) // buffered
+	async.OnHit(ctx, "key-2", FileMeta{}) // dropped
+	cancel()
+
+	close(release)
+	async.Close()
+	async.OnHit(ctx, "key-3", FileMeta{}) // dropped, as closed
+
+	assert.Equal(t, []string{"key-1"}, hits)
+	assert.Equal(t, int64(2), async.Dropped())
+}
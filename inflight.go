@@ -0,0 +1,94 @@
+package fcache
+
+import (
+	"context"
+	"sync"
+)
+
+// inflight deduplicates concurrent loads of the same key.
+// The first caller becomes the leader and runs the loader, others wait for
+// its result.
+type inflight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call represents a single load of the file.
+type call struct {
+	done chan struct{}
+
+	// fields below are guarded by inflight.mu
+	waiters  int
+	finished bool
+
+	// fields below are set before done is closed
+	meta    FileMeta
+	err     error
+	content spool // nil, if the loaded content can't be shared
+}
+
+// join returns the call for the key and reports whether the caller is the
+// leader, i.e. it has to load the file and finish the call.
+func (g *inflight) join(key string) (c *call, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		return c, false
+	}
+
+	c = &call{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+// finish sets the result of the call and wakes up the waiters.
+// A reference to the content is reserved for each waiter.
+func (g *inflight) finish(key string, c *call, meta FileMeta, content spool, err error) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	c.finished = true
+	c.meta, c.err = meta, err
+	if content != nil && c.waiters > 0 {
+		content.acquire(c.waiters)
+		c.content = content
+	}
+	g.mu.Unlock()
+
+	close(c.done)
+}
+
+// wait waits for the call to be finished. If the context is done before,
+// the waiter leaves the call and the context's error is returned.
+func (g *inflight) wait(ctx context.Context, c *call) error {
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	g.leave(c)
+	return ctx.Err()
+}
+
+// leave leaves the call without waiting for its result.
+func (g *inflight) leave(c *call) {
+	g.mu.Lock()
+	if !c.finished {
+		c.waiters--
+		g.mu.Unlock()
+		return
+	}
+	g.mu.Unlock()
+
+	// the call has been finished concurrently, releasing the reserved reference
+	<-c.done
+	if c.content != nil {
+		c.content.release()
+	}
+}
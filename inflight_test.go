@@ -0,0 +1,195 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_GetFile_Coalesced(t *testing.T) {
+	const callers = 5
+
+	t.Run("success", func(t *testing.T) {
+		svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+
+		release := make(chan struct{})
+		var loads int64
+		req := GetRequest{
+			Key: "key",
+			TTL: time.Minute,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				atomic.AddInt64(&loads, 1)
+				<-release
+				return io.NopCloser(strings.NewReader("some file data")), FileMeta{Name: "a.txt"}, nil
+			},
+		}
+
+		var spoolPath string
+		wg := &sync.WaitGroup{}
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rd, meta, err := svc.GetFile(context.Background(), req)
+				require.NoError(t, err)
+				assert.Equal(t, "a.txt", meta.Name)
+
+				if sr, ok := rd.(*spoolReader); ok {
+					spoolPath = sr.spool.path
+				}
+
+				bts, err := io.ReadAll(rd)
+				require.NoError(t, err)
+				assert.Equal(t, []byte("some file data"), bts)
+				require.NoError(t, rd.Close())
+			}()
+		}
+
+		waitWaiters(t, &svc.inflight, "key", callers-1)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int64(1), atomic.LoadInt64(&loads))
+		assert.Equal(t, CacheStats{Misses: 1, Coalesced: callers - 1}, svc.CacheStats)
+
+		require.NotEmpty(t, spoolPath)
+		_, err := os.Stat(spoolPath)
+		assert.True(t, errors.Is(err, os.ErrNotExist), "spool file must be removed")
+	})
+
+	t.Run("loader error", func(t *testing.T) {
+		svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+
+		release := make(chan struct{})
+		req := GetRequest{
+			Key: "key",
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				<-release
+				return nil, FileMeta{}, errors.New("origin failure")
+			},
+		}
+
+		wg := &sync.WaitGroup{}
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := svc.GetFile(context.Background(), req)
+				assert.ErrorContains(t, err, "loader returned error: origin failure")
+			}()
+		}
+
+		waitWaiters(t, &svc.inflight, "key", callers-1)
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("waiter's context canceled", func(t *testing.T) {
+		svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+
+		release := make(chan struct{})
+		req := GetRequest{
+			Key: "key",
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				<-release
+				return io.NopCloser(strings.NewReader("some file data")), FileMeta{}, nil
+			},
+		}
+
+		leaderDone := make(chan struct{})
+		go func() {
+			defer close(leaderDone)
+			rd, _, err := svc.GetFile(context.Background(), req)
+			require.NoError(t, err)
+			require.NoError(t, rd.Close())
+		}()
+
+		waitWaiters(t, &svc.inflight, "key", 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		waiterDone := make(chan struct{})
+		go func() {
+			defer close(waiterDone)
+			_, _, err := svc.GetFile(ctx, req)
+			assert.ErrorIs(t, err, context.Canceled)
+		}()
+
+		waitWaiters(t, &svc.inflight, "key", 1)
+		cancel()
+		<-waiterDone
+
+		svc.inflight.mu.Lock()
+		assert.Equal(t, 0, svc.inflight.calls["key"].waiters)
+		svc.inflight.mu.Unlock()
+
+		close(release)
+		<-leaderDone
+	})
+}
+
+func TestLoadingCache_GetURL_Coalesced(t *testing.T) {
+	svc := NewLoadingCache(newTestFS(t, time.Now()), WithLogger(NopLogger()))
+
+	release := make(chan struct{})
+	req := GetRequest{
+		Key: "key",
+		TTL: time.Minute,
+		Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			<-release
+			return io.NopCloser(strings.NewReader("some file data")), FileMeta{Name: "a.txt"}, nil
+		},
+	}
+
+	var rd io.ReadCloser
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		var err error
+		rd, _, err = svc.GetFile(context.Background(), req)
+		require.NoError(t, err)
+	}()
+
+	waitWaiters(t, &svc.inflight, "key", 0)
+
+	urlDone := make(chan struct{})
+	go func() {
+		defer close(urlDone)
+		u, meta, err := svc.GetURL(context.Background(), req, GetURLParams{})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(u, "file://"))
+		assert.Equal(t, "a.txt", meta.Name)
+	}()
+
+	waitWaiters(t, &svc.inflight, "key", 1)
+	close(release)
+	<-leaderDone
+	<-urlDone
+
+	// leader's reader is the last reference to the spool file
+	sr, ok := rd.(*spoolReader)
+	require.True(t, ok)
+	require.NoError(t, rd.Close())
+	_, err := os.Stat(sr.spool.path)
+	assert.True(t, errors.Is(err, os.ErrNotExist), "spool file must be removed")
+	assert.Equal(t, CacheStats{Misses: 1, Coalesced: 1}, svc.CacheStats)
+}
+
+// waitWaiters waits until the in-flight call for the key has the given
+// amount of waiters.
+func waitWaiters(t *testing.T, g *inflight, key string, waiters int) {
+	require.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c, ok := g.calls[key]
+		return ok && c.waiters == waiters
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,66 @@
+package fcache
+
+import "sync"
+
+// keyLocks provides mutexes per key, which exist only while they are held
+// or awaited.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int // guarded by keyLocks.mu
+}
+
+// lock locks the key and returns the function to unlock it.
+func (k *keyLocks) lock(key string) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyLock{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.mu.Lock()
+
+	return k.unlocker(key, l)
+}
+
+// tryLock locks the key, if it is not locked or awaited, and returns the
+// function to unlock it.
+func (k *keyLocks) tryLock(key string) (unlock func(), ok bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, locked := k.locks[key]; locked {
+		return nil, false
+	}
+
+	if k.locks == nil {
+		k.locks = map[string]*keyLock{}
+	}
+	l := &keyLock{refs: 1}
+	l.mu.Lock()
+	k.locks[key] = l
+
+	return k.unlocker(key, l), true
+}
+
+func (k *keyLocks) unlocker(key string, l *keyLock) func() {
+	return func() {
+		l.mu.Unlock()
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package fcache
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLocks(t *testing.T) {
+	var locks keyLocks
+	counters := map[string]*int{"key-1": new(int), "key-2": new(int)}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := []string{"key-1", "key-2"}[i%2]
+			unlock := locks.lock(key)
+			defer unlock()
+			*counters[key]++ // guarded by the key's lock only
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, *counters["key-1"])
+	assert.Equal(t, 50, *counters["key-2"])
+	assert.Empty(t, locks.locks)
+}
+
+func TestKeyLocks_tryLock(t *testing.T) {
+	var locks keyLocks
+
+	unlock, ok := locks.tryLock("key")
+	assert.True(t, ok)
+
+	_, ok = locks.tryLock("key")
+	assert.False(t, ok)
+
+	unlockOther, ok := locks.tryLock("other")
+	assert.True(t, ok)
+	unlockOther()
+
+	unlock()
+	unlock, ok = locks.tryLock("key")
+	assert.True(t, ok)
+	unlock()
+	assert.Empty(t, locks.locks)
+}
@@ -1,6 +1,12 @@
 package fcache
 
-import "log"
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
 
 // Logger defines a single method for logging in caches.
 type Logger interface {
@@ -17,3 +23,80 @@ func (nopLogger) Printf(string, ...interface{})             {}
 
 // NopLogger returns a no-op logger.
 func NopLogger() Logger { return nopLogger{} }
+
+// Level is the severity of the log record.
+// Values match the levels of log/slog.
+type Level int
+
+// Log levels.
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// StructuredLogger logs leveled records with attributes.
+type StructuredLogger interface {
+	Log(ctx context.Context, level Level, msg string, attrs ...Attr)
+}
+
+// Attribute keys, used in log records.
+const (
+	LogKey      = "key"      // key of the file
+	LogOp       = "op"       // operation, one of Op values
+	LogDuration = "duration" // duration of the operation
+	LogCount    = "count"    // number of affected files
+	LogError    = "error"    // error of the operation
+)
+
+// ErrorAttr makes an error attribute.
+func ErrorAttr(err error) Attr { return Attr{Key: LogError, Value: err} }
+
+// DurationAttr makes a duration attribute.
+func DurationAttr(key string, d time.Duration) Attr { return Attr{Key: key, Value: d} }
+
+// PrintfLogger adapts Logger to StructuredLogger. The level is put in
+// the message's prefix, as "[WARN]", and attributes are appended to the
+// message as key=value pairs.
+func PrintfLogger(log Logger) StructuredLogger { return printfLogger{log: log} }
+
+type printfLogger struct{ log Logger }
+
+func (p printfLogger) Log(_ context.Context, level Level, msg string, attrs ...Attr) {
+	sb := strings.Builder{}
+	sb.WriteString(msg)
+	for _, attr := range attrs {
+		if s, ok := attr.Value.(string); ok {
+			_, _ = fmt.Fprintf(&sb, " %s=%q", attr.Key, s)
+			continue
+		}
+		_, _ = fmt.Fprintf(&sb, " %s=%v", attr.Key, attr.Value)
+	}
+	p.log.Printf("[%s] %s", level, sb.String())
+}
+
+func (l *LoadingCache) logger() StructuredLogger {
+	if l.StructuredLog != nil {
+		return l.StructuredLog
+	}
+	return PrintfLogger(l.Log)
+}
+
+func (l *LoadingCache) log(ctx context.Context, level Level, msg string, attrs ...Attr) {
+	l.logger().Log(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,60 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintfLogger(t *testing.T) {
+	var lines []string
+	log := PrintfLogger(loggerFunc(func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}))
+
+	log.Log(context.Background(), LevelWarn, "failed to reload file",
+		StringAttr(LogKey, "some key"),
+		DurationAttr(LogDuration, time.Second),
+		ErrorAttr(errors.New("origin failure")),
+	)
+	log.Log(context.Background(), LevelDebug, "evicted cache items", Int64Attr(LogCount, 2))
+
+	assert.Equal(t, []string{
+		`[WARN] failed to reload file key="some key" duration=1s error=origin failure`,
+		`[DEBUG] evicted cache items count=2`,
+	}, lines)
+}
+
+func TestLoadingCache_StructuredLogger(t *testing.T) {
+	log := &logRecorder{}
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()), WithStructuredLogger(log))
+
+	require.NoError(t, svc.Set(context.Background(), "key", time.Hour, FileMeta{}, stringReader("1234")))
+	require.NoError(t, svc.Delete(context.Background(), "key"))
+
+	assert.Equal(t, []logRecord{
+		{level: LevelDebug, msg: "set file", attrs: []Attr{StringAttr(LogKey, "key"), StringAttr(LogOp, "set")}},
+		{level: LevelDebug, msg: "deleted file", attrs: []Attr{StringAttr(LogKey, "key"), StringAttr(LogOp, "delete")}},
+	}, log.records)
+}
+
+type loggerFunc func(format string, args ...interface{})
+
+func (f loggerFunc) Printf(format string, args ...interface{}) { f(format, args...) }
+
+type logRecord struct {
+	level Level
+	msg   string
+	attrs []Attr
+}
+
+type logRecorder struct{ records []logRecord }
+
+func (r *logRecorder) Log(_ context.Context, level Level, msg string, attrs ...Attr) {
+	r.records = append(r.records, logRecord{level: level, msg: msg, attrs: attrs})
+}
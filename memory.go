@@ -0,0 +1,228 @@
+package fcache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Memory implements Store in the process memory.
+// It keeps the total size of files within the configured capacity and
+// evicts least recently read files, when the new one doesn't fit.
+type Memory struct {
+	log     Logger
+	maxSize int64
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // front is the most recently used item
+	size  int64
+
+	// mockable fields
+	now func() time.Time
+}
+
+type memoryItem struct {
+	meta FileMeta
+	data []byte
+}
+
+// NewMemory makes new instance of Memory.
+// maxSize limits the total size of stored files in bytes, zero means no limit.
+func NewMemory(maxSize int64, log Logger) *Memory {
+	return &Memory{
+		log:     log,
+		maxSize: maxSize,
+		items:   map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Meta returns meta information about the file at underlying key.
+func (m *Memory) Meta(_ context.Context, key string) (FileMeta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return FileMeta{}, ErrNotFound
+	}
+
+	return copyMeta(el.Value.(*memoryItem).meta), nil
+}
+
+// UpdateMeta updates meta information about the file at underlying key.
+func (m *Memory) UpdateMeta(_ context.Context, key string, meta FileMeta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	item := el.Value.(*memoryItem)
+	meta = copyMeta(meta)
+	item.meta.Name = meta.Name
+	item.meta.Mime = meta.Mime
+	item.meta.Meta = meta.Meta
+
+	return nil
+}
+
+// Get returns the reader of the file's content and marks the file as
+// recently used.
+func (m *Memory) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	m.lru.MoveToFront(el)
+
+	// data is never modified after put, so it's safe to share it
+	return io.NopCloser(bytes.NewReader(el.Value.(*memoryItem).data)), nil
+}
+
+// GetRange returns the reader of the file's part.
+func (m *Memory) GetRange(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	m.lru.MoveToFront(el)
+
+	data := el.Value.(*memoryItem).data
+	return io.NopCloser(sectionReader(bytes.NewReader(data), offset, length)), nil
+}
+
+// GetURL is not supported by Memory, as files are not accessible outside
+// the process, ErrNotSupported is returned for existing files.
+func (m *Memory) GetURL(ctx context.Context, key string, _ GetURLParams) (string, error) {
+	if _, err := m.Meta(ctx, key); err != nil {
+		return "", err
+	}
+
+	return "", ErrNotSupported
+}
+
+// Put puts file into memory, evicting least recently used files, if the
+// capacity is exceeded.
+func (m *Memory) Put(_ context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+	defer func() {
+		if err := rd.Close(); err != nil {
+			m.log.Printf("[WARN] failed to close reader: %v", err)
+		}
+	}()
+
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return fmt.Errorf("read file content: %w", err)
+	}
+
+	size := int64(len(data))
+	if m.maxSize > 0 && size > m.maxSize {
+		return fmt.Errorf("file of %d bytes exceeds store capacity of %d bytes", size, m.maxSize)
+	}
+
+	meta = copyMeta(meta)
+	meta.Key = key
+	meta.Size = size
+	meta.CreatedAt = m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+
+	for m.maxSize > 0 && m.size+size > m.maxSize {
+		m.remove(m.lru.Back().Value.(*memoryItem).meta.Key)
+	}
+
+	m.items[key] = m.lru.PushFront(&memoryItem{meta: meta, data: data})
+	m.size += size
+
+	return nil
+}
+
+// Remove removes file by its key.
+func (m *Memory) Remove(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.remove(key) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Stat returns store stats.
+func (m *Memory) Stat(context.Context) (StoreStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return StoreStats{Keys: len(m.items), Size: m.size}, nil
+}
+
+// Keys returns all keys, present in store.
+func (m *Memory) Keys(context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]string, 0, len(m.items))
+	for key := range m.items {
+		res = append(res, key)
+	}
+
+	return res, nil
+}
+
+// List lists all files in store.
+func (m *Memory) List(context.Context) ([]FileMeta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]FileMeta, 0, len(m.items))
+	for _, el := range m.items {
+		res = append(res, copyMeta(el.Value.(*memoryItem).meta))
+	}
+
+	return res, nil
+}
+
+// remove removes item under the key, must be called under lock.
+func (m *Memory) remove(key string) bool {
+	el, ok := m.items[key]
+	if !ok {
+		return false
+	}
+
+	m.lru.Remove(el)
+	delete(m.items, key)
+	m.size -= int64(len(el.Value.(*memoryItem).data))
+
+	return true
+}
+
+// copyMeta makes a deep copy of meta to prevent sharing the map.
+func copyMeta(meta FileMeta) FileMeta {
+	src := meta.Meta
+	meta.Meta = make(map[string]string, len(src))
+	for k, v := range src {
+		meta.Meta[k] = v
+	}
+	return meta
+}
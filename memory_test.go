@@ -0,0 +1,169 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_PutGet(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	svc := NewMemory(0, NopLogger())
+	svc.now = func() time.Time { return now }
+
+	meta := FileMeta{
+		Name: "a.txt",
+		Mime: "text/plain",
+		Meta: map[string]string{metaInvalidateAtKey: "some time"},
+	}
+	err := svc.Put(context.Background(), "key", meta, io.NopCloser(strings.NewReader("some file data")))
+	require.NoError(t, err)
+
+	meta.Meta["k"] = "v" // must not affect stored meta
+
+	res, err := svc.Meta(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, FileMeta{
+		Name:      "a.txt",
+		Mime:      "text/plain",
+		Meta:      map[string]string{metaInvalidateAtKey: "some time"},
+		Size:      14,
+		Key:       "key",
+		CreatedAt: now,
+	}, res)
+
+	rd, err := svc.Get(context.Background(), "key")
+	require.NoError(t, err)
+	bts, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("some file data"), bts)
+
+	require.NoError(t, svc.UpdateMeta(context.Background(), "key", FileMeta{Name: "b.txt", Meta: map[string]string{"k": "v"}}))
+	res, err = svc.Meta(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, FileMeta{
+		Name:      "b.txt",
+		Meta:      map[string]string{"k": "v"},
+		Size:      14,
+		Key:       "key",
+		CreatedAt: now,
+	}, res)
+
+	_, err = svc.GetURL(context.Background(), "key", GetURLParams{})
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	require.NoError(t, svc.Remove(context.Background(), "key"))
+	_, err = svc.Meta(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Get(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, svc.Remove(context.Background(), "key"), ErrNotFound)
+	assert.ErrorIs(t, svc.UpdateMeta(context.Background(), "key", FileMeta{}), ErrNotFound)
+}
+
+func TestMemory_Eviction(t *testing.T) {
+	ctx := context.Background()
+	svc := NewMemory(10, NopLogger())
+
+	put := func(key, data string) error {
+		return svc.Put(ctx, key, FileMeta{}, io.NopCloser(strings.NewReader(data)))
+	}
+
+	require.NoError(t, put("key-1", "1234"))
+	require.NoError(t, put("key-2", "1234"))
+
+	// marking key-1 as recently used
+	_, err := svc.Get(ctx, "key-1")
+	require.NoError(t, err)
+
+	require.NoError(t, put("key-3", "1234"))
+
+	keys, err := svc.Keys(ctx)
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"key-1", "key-3"}, keys)
+
+	stat, err := svc.Stat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StoreStats{Keys: 2, Size: 8}, stat)
+
+	// replacing the file must not count its previous size
+	require.NoError(t, put("key-3", "123456"))
+	stat, err = svc.Stat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StoreStats{Keys: 2, Size: 10}, stat)
+
+	assert.Error(t, put("key-4", "12345678901"))
+	stat, err = svc.Stat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StoreStats{Keys: 2, Size: 10}, stat)
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	svc := NewMemory(100, NopLogger())
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key-%d", i%10)
+			err := svc.Put(ctx, key, FileMeta{}, io.NopCloser(strings.NewReader("0123456789")))
+			assert.NoError(t, err)
+			if rd, err := svc.Get(ctx, key); err == nil {
+				_, err = io.ReadAll(rd)
+				assert.NoError(t, err)
+			}
+			_, err = svc.List(ctx)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	stat, err := svc.Stat(ctx)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, stat.Size, int64(100))
+
+	files, err := svc.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, files, stat.Keys)
+}
+
+func TestMemory_LoadingCache(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	ctx := context.Background()
+
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+	svc.now = func() time.Time { return now }
+
+	rd, _, err := svc.GetFile(ctx, GetRequest{
+		Key: "key",
+		TTL: 15 * time.Minute,
+		Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			return io.NopCloser(strings.NewReader("some file data")), FileMeta{Name: "a.txt"}, nil
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, rd.Close())
+
+	invalidated, err := svc.Invalidate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), invalidated)
+
+	svc.now = func() time.Time { return now.Add(time.Hour) }
+	invalidated, err = svc.Invalidate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), invalidated)
+
+	_, err = svc.Store.Meta(ctx, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,141 @@
+package fcache
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Op names the cache operation in metrics.
+type Op string
+
+// Operations, reported to Metrics and logs.
+const (
+	OpGetFile    Op = "get_file"    // LoadingCache.GetFile call
+	OpGetURL     Op = "get_url"     // LoadingCache.GetURL call or Store.GetURL
+	OpMeta       Op = "meta"        // Store.Meta
+	OpGet        Op = "get"         // Store.Get
+	OpLoad       Op = "load"        // Loader call
+	OpPut        Op = "put"         // Store.Put
+	OpUpdateMeta Op = "update_meta" // Store.UpdateMeta
+	OpRemove     Op = "remove"      // Store.Remove
+	OpSet        Op = "set"         // LoadingCache.Set call
+	OpDelete     Op = "delete"      // LoadingCache.Delete call
+	OpTouch      Op = "touch"       // LoadingCache.Touch call
+	OpInvalidate Op = "invalidate"  // removal of expired or invalidated files
+	OpEvict      Op = "evict"       // eviction of files
+	OpReload     Op = "reload"      // background reload of the file
+)
+
+// Metrics receives measurements of cache operations.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Hit is called on cache hit in GetFile or GetURL.
+	Hit(op Op)
+	// Miss is called on cache miss in GetFile or GetURL.
+	Miss(op Op)
+	// Coalesced is called on cache miss, which waits for the concurrent load
+	// of the same key.
+	Coalesced(op Op)
+	// Error is called, when the operation fails.
+	Error(op Op)
+	// Loaded is called with the duration of each loader's call.
+	Loaded(d time.Duration, err error)
+	// BytesLoaded is called with the number of bytes, read from the loader.
+	BytesLoaded(n int64)
+	// BytesServed is called with the number of bytes, read by the caller
+	// from the reader, returned by GetFile.
+	BytesServed(n int64)
+	// Invalidated is called after each invalidation run.
+	Invalidated(d time.Duration, removed int64, err error)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Hit(Op)                                  {}
+func (nopMetrics) Miss(Op)                                 {}
+func (nopMetrics) Coalesced(Op)                            {}
+func (nopMetrics) Error(Op)                                {}
+func (nopMetrics) Loaded(time.Duration, error)             {}
+func (nopMetrics) BytesLoaded(int64)                       {}
+func (nopMetrics) BytesServed(int64)                       {}
+func (nopMetrics) Invalidated(time.Duration, int64, error) {}
+
+func (l *LoadingCache) metrics() Metrics {
+	if l.Metrics == nil {
+		return nopMetrics{}
+	}
+	return l.Metrics
+}
+
+func (l *LoadingCache) hit(ctx context.Context, op Op, key string, meta FileMeta) {
+	atomic.AddInt64(&l.Hits, 1)
+	l.metrics().Hit(op)
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnHit(ctx, key, meta) })
+}
+
+func (l *LoadingCache) miss(ctx context.Context, op Op, key string) {
+	atomic.AddInt64(&l.Misses, 1)
+	l.metrics().Miss(op)
+	l.hook(ctx, FileMeta{}, func(h Hooks, meta FileMeta) { h.OnMiss(ctx, key, meta) })
+}
+
+func (l *LoadingCache) coalesced(op Op) {
+	atomic.AddInt64(&l.Coalesced, 1)
+	l.metrics().Coalesced(op)
+}
+
+// fail counts the failed operation and reports it to metrics and hooks.
+func (l *LoadingCache) fail(ctx context.Context, op Op, key string, meta FileMeta, err error) {
+	atomic.AddInt64(&l.Errors, 1)
+	l.metrics().Error(op)
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnError(ctx, key, meta, op, err) })
+}
+
+// countReader wraps the reader to report the number of read bytes.
+// Seeker and ReaderAt implementations of the reader are preserved.
+func countReader(rd io.ReadCloser, add func(n int64)) io.ReadCloser {
+	cr := &countingReader{ReadCloser: rd, add: add}
+	s, ok := rd.(io.Seeker)
+	if !ok {
+		return cr
+	}
+
+	crs := &countingReadSeeker{countingReader: cr, Seeker: s}
+	if at, ok := rd.(io.ReaderAt); ok {
+		return &countingReadSeekerAt{countingReadSeeker: crs, at: at}
+	}
+	return crs
+}
+
+type countingReader struct {
+	io.ReadCloser
+	add func(n int64)
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.add(int64(n))
+	}
+	return n, err
+}
+
+type countingReadSeeker struct {
+	*countingReader
+	io.Seeker
+}
+
+type countingReadSeekerAt struct {
+	*countingReadSeeker
+	at io.ReaderAt
+}
+
+func (r *countingReadSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.at.ReadAt(p, off)
+	if n > 0 {
+		r.add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,199 @@
+// Package metrics provides the Prometheus collector for fcache.LoadingCache.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultSampleInterval = time.Minute
+
+// Collector implements prometheus.Collector and fcache.Metrics.
+// It should be passed to the cache with fcache.WithMetrics and registered
+// in the prometheus registry.
+type Collector struct {
+	hits      *prometheus.CounterVec
+	misses    *prometheus.CounterVec
+	coalesced *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+
+	loadDuration *prometheus.HistogramVec
+	bytesLoaded  prometheus.Counter
+	bytesServed  prometheus.Counter
+
+	invalidationDuration *prometheus.HistogramVec
+	invalidated          prometheus.Counter
+
+	storeSize      prometheus.Gauge
+	storeKeys      prometheus.Gauge
+	storeStatFails prometheus.Counter
+}
+
+// Opts defines options of the collector.
+type Opts struct {
+	// Namespace and Subsystem are prepended to the metric names,
+	// "fcache" namespace is used by default.
+	Namespace string
+	Subsystem string
+	// ConstLabels are attached to all metrics.
+	ConstLabels prometheus.Labels
+	// LoadBuckets defines buckets of the loader latency histogram,
+	// prometheus.DefBuckets are used by default.
+	LoadBuckets []float64
+}
+
+// NewCollector makes new instance of Collector.
+func NewCollector(opts Opts) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "fcache"
+	}
+	if len(opts.LoadBuckets) == 0 {
+		opts.LoadBuckets = prometheus.DefBuckets
+	}
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: opts.ConstLabels,
+		}, labels)
+	}
+
+	gauge := func(name, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: opts.ConstLabels,
+		})
+	}
+
+	histogram := func(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: opts.ConstLabels,
+			Buckets:     buckets,
+		}, labels)
+	}
+
+	return &Collector{
+		hits:      counter("hits_total", "Number of cache hits.", "op"),
+		misses:    counter("misses_total", "Number of cache misses.", "op"),
+		coalesced: counter("coalesced_total", "Number of misses, coalesced with the concurrent load.", "op"),
+		errors:    counter("errors_total", "Number of failed operations.", "op"),
+
+		loadDuration: histogram("load_duration_seconds", "Latency of loader calls.", opts.LoadBuckets, "result"),
+		bytesLoaded:  counter("loaded_bytes_total", "Number of bytes, read from loaders.").WithLabelValues(),
+		bytesServed:  counter("served_bytes_total", "Number of bytes, served to callers.").WithLabelValues(),
+
+		invalidationDuration: histogram("invalidation_duration_seconds", "Duration of invalidation runs.",
+			prometheus.DefBuckets, "result"),
+		invalidated: counter("invalidated_total", "Number of files, removed by invalidation.").WithLabelValues(),
+
+		storeSize:      gauge("store_size_bytes", "Size of all files in the store, as of the last sample."),
+		storeKeys:      gauge("store_keys", "Number of files in the store, as of the last sample."),
+		storeStatFails: counter("store_stat_errors_total", "Number of failed store size samples.").WithLabelValues(),
+	}
+}
+
+// Run samples the size of the store with the given interval, until the
+// context is canceled. The store is sampled right away on start.
+// The store is sampled every minute, if the interval isn't positive.
+func (c *Collector) Run(ctx context.Context, store fcache.Store, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultSampleInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.Sample(ctx, store)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sample samples the size of the store.
+func (c *Collector) Sample(ctx context.Context, store fcache.Store) {
+	stat, err := store.Stat(ctx)
+	if err != nil {
+		c.storeStatFails.Inc()
+		return
+	}
+
+	c.storeSize.Set(float64(stat.Size))
+	c.storeKeys.Set(float64(stat.Keys))
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, col := range c.collectors() {
+		col.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, col := range c.collectors() {
+		col.Collect(ch)
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.hits, c.misses, c.coalesced, c.errors,
+		c.loadDuration, c.bytesLoaded, c.bytesServed,
+		c.invalidationDuration, c.invalidated,
+		c.storeSize, c.storeKeys, c.storeStatFails,
+	}
+}
+
+// Hit implements fcache.Metrics.
+func (c *Collector) Hit(op fcache.Op) { c.hits.WithLabelValues(string(op)).Inc() }
+
+// Miss implements fcache.Metrics.
+func (c *Collector) Miss(op fcache.Op) { c.misses.WithLabelValues(string(op)).Inc() }
+
+// Coalesced implements fcache.Metrics.
+func (c *Collector) Coalesced(op fcache.Op) { c.coalesced.WithLabelValues(string(op)).Inc() }
+
+// Error implements fcache.Metrics.
+func (c *Collector) Error(op fcache.Op) { c.errors.WithLabelValues(string(op)).Inc() }
+
+// Loaded implements fcache.Metrics.
+func (c *Collector) Loaded(d time.Duration, err error) {
+	c.loadDuration.WithLabelValues(result(err)).Observe(d.Seconds())
+}
+
+// BytesLoaded implements fcache.Metrics.
+func (c *Collector) BytesLoaded(n int64) { c.bytesLoaded.Add(float64(n)) }
+
+// BytesServed implements fcache.Metrics.
+func (c *Collector) BytesServed(n int64) { c.bytesServed.Add(float64(n)) }
+
+// Invalidated implements fcache.Metrics.
+func (c *Collector) Invalidated(d time.Duration, removed int64, err error) {
+	c.invalidationDuration.WithLabelValues(result(err)).Observe(d.Seconds())
+	c.invalidated.Add(float64(removed))
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(Opts{})
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+
+	store := fcache.NewMemory(0, fcache.NopLogger())
+	svc := fcache.NewLoadingCache(store, fcache.WithLogger(fcache.NopLogger()), fcache.WithMetrics(c))
+
+	for i := 0; i < 2; i++ {
+		rd, _, err := svc.GetFile(context.Background(), fcache.GetRequest{
+			Key: "key",
+			TTL: time.Hour,
+			Loader: func(ctx context.Context) (io.ReadCloser, fcache.FileMeta, error) {
+				return io.NopCloser(strings.NewReader("1234")), fcache.FileMeta{Size: 4}, nil
+			},
+		})
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, rd)
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+	}
+
+	_, _, err := svc.GetFile(context.Background(), fcache.GetRequest{
+		Key: "failed",
+		Loader: func(ctx context.Context) (io.ReadCloser, fcache.FileMeta, error) {
+			return nil, fcache.FileMeta{}, errors.New("origin failure")
+		},
+	})
+	require.Error(t, err)
+
+	_, err = svc.Invalidate(context.Background())
+	require.NoError(t, err)
+
+	c.Sample(context.Background(), store)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.hits.WithLabelValues("get_file")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.misses.WithLabelValues("get_file")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.errors.WithLabelValues("load")))
+	assert.Equal(t, float64(4), testutil.ToFloat64(c.bytesLoaded))
+	assert.Equal(t, float64(8), testutil.ToFloat64(c.bytesServed))
+	assert.Equal(t, float64(4), testutil.ToFloat64(c.storeSize))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.storeKeys))
+
+	cnt, err := testutil.GatherAndCount(reg, "fcache_load_duration_seconds", "fcache_invalidation_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 3, cnt) // ok and error loads, ok invalidation
+}
+
+func TestCollector_Run(t *testing.T) {
+	t.Run("sample periodically", func(t *testing.T) {
+		c := NewCollector(Opts{Namespace: "test"})
+		store := fcache.NewMemory(0, fcache.NopLogger())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.ErrorIs(t, c.Run(ctx, store, time.Millisecond), context.Canceled)
+		}()
+
+		require.NoError(t, store.Put(ctx, "key", fcache.FileMeta{Size: 4}, io.NopCloser(strings.NewReader("1234"))))
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(c.storeSize) == 4
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+	})
+
+	t.Run("zero interval", func(t *testing.T) {
+		c := NewCollector(Opts{Namespace: "test"})
+		store := fcache.NewMemory(0, fcache.NopLogger())
+		require.NoError(t, store.Put(context.Background(), "key", fcache.FileMeta{Size: 4},
+			io.NopCloser(strings.NewReader("1234"))))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.ErrorIs(t, c.Run(ctx, store, 0), context.Canceled)
+		}()
+
+		// sampled on start
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(c.storeSize) == 4
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+	})
+}
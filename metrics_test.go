@@ -0,0 +1,117 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_Metrics(t *testing.T) {
+	m := &metricsRecorder{counts: map[string]int{}}
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()), WithMetrics(m))
+
+	for i := 0; i < 2; i++ {
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			TTL:    time.Hour,
+			Loader: stringLoader("1234"),
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "1234")
+	}
+
+	_, _, err := svc.GetURL(context.Background(), GetRequest{Key: "key"}, GetURLParams{})
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	_, _, err = svc.GetFile(context.Background(), GetRequest{
+		Key: "failed",
+		Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			return nil, FileMeta{}, errors.New("origin failure")
+		},
+	})
+	assert.Error(t, err)
+
+	_, err = svc.Invalidate(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{
+		"hit:get_file":   1,
+		"hit:get_url":    1,
+		"miss:get_file":  2,
+		"error:get_url":  1,
+		"error:load":     1,
+		"loaded":         1,
+		"loaded_failure": 1,
+		"invalidated":    1,
+	}, m.counts)
+	assert.Equal(t, int64(4), m.bytesLoaded)
+	assert.Equal(t, int64(8), m.bytesServed)
+}
+
+func TestCountReader(t *testing.T) {
+	var n int64
+	add := func(v int64) { n += v }
+
+	rd := countReader(io.NopCloser(strings.NewReader("1234")), add)
+	_, ok := rd.(io.Seeker)
+	assert.False(t, ok)
+
+	rd = countReader(nopSeekCloser{strings.NewReader("1234")}, add)
+	seeker, ok := rd.(io.Seeker)
+	require.True(t, ok)
+	_, err := seeker.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+	assertReader(t, rd, "34")
+	assert.Equal(t, int64(2), n)
+}
+
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }
+
+type metricsRecorder struct {
+	mu          sync.Mutex
+	counts      map[string]int
+	bytesLoaded int64
+	bytesServed int64
+}
+
+func (m *metricsRecorder) inc(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name]++
+}
+
+func (m *metricsRecorder) Hit(op Op)       { m.inc("hit:" + string(op)) }
+func (m *metricsRecorder) Miss(op Op)      { m.inc("miss:" + string(op)) }
+func (m *metricsRecorder) Coalesced(op Op) { m.inc("coalesced:" + string(op)) }
+func (m *metricsRecorder) Error(op Op)     { m.inc("error:" + string(op)) }
+
+func (m *metricsRecorder) Loaded(_ time.Duration, err error) {
+	if err != nil {
+		m.inc("loaded_failure")
+		return
+	}
+	m.inc("loaded")
+}
+
+func (m *metricsRecorder) BytesLoaded(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesLoaded += n
+}
+
+func (m *metricsRecorder) BytesServed(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesServed += n
+}
+
+func (m *metricsRecorder) Invalidated(time.Duration, int64, error) { m.inc("invalidated") }
@@ -0,0 +1,154 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrCachedFailure is returned, when the loader has recently failed to load
+// the file under the same key and the failure has been cached.
+// The returned error also wraps the original loader's error.
+var ErrCachedFailure = errors.New("cached loader failure")
+
+// ErrUncacheable should be wrapped by the loader's error to report that the
+// result of the load must not be put into the store. Such results are not
+// counted as load failures and are never remembered by negative caching,
+// the error is just returned to the caller.
+var ErrUncacheable = errors.New("uncacheable")
+
+// cachedFailure wraps the cached loader's error.
+type cachedFailure struct{ err error }
+
+func (e *cachedFailure) Error() string        { return fmt.Sprintf("%v: %v", ErrCachedFailure, e.err) }
+func (e *cachedFailure) Unwrap() error        { return e.err }
+func (e *cachedFailure) Is(target error) bool { return target == ErrCachedFailure }
+
+// callLoader calls the request's loader, traces it, reports its metrics and
+// events, and remembers its failure, if negative caching is enabled and
+// the error is cacheable.
+func (l *LoadingCache) callLoader(ctx context.Context, req GetRequest) (io.ReadCloser, FileMeta, error) {
+	l.hook(ctx, FileMeta{}, func(h Hooks, meta FileMeta) { h.OnLoadStart(ctx, req.Key, meta) })
+
+	start := time.Now()
+	spanCtx, span := l.tracer().Start(ctx, "fcache.Loader", StringAttr(AttrKey, req.Key))
+	rd, meta, err := req.Loader(spanCtx)
+	endSpan(span, err, Int64Attr(AttrSize, meta.Size))
+
+	// uncacheable result is not a failure, the loader just forbids to store it
+	loadErr := err
+	if errors.Is(err, ErrUncacheable) {
+		loadErr = nil
+	}
+	l.metrics().Loaded(time.Since(start), loadErr)
+
+	l.hook(ctx, meta, func(h Hooks, meta FileMeta) { h.OnLoadFinish(ctx, req.Key, meta, err) })
+
+	if err == nil {
+		if l.Metrics != nil {
+			rd = countReader(rd, l.Metrics.BytesLoaded)
+		}
+		return rd, meta, nil
+	}
+
+	if loadErr == nil {
+		return rd, meta, err
+	}
+
+	l.fail(ctx, OpLoad, req.Key, meta, err)
+
+	if l.NegativeTTL <= 0 {
+		return rd, meta, err
+	}
+
+	cacheable := l.CacheableError
+	if cacheable == nil {
+		cacheable = defaultCacheableError
+	}
+
+	if cacheable(err) {
+		l.failures.remember(req.Key, err, l.now().Add(l.NegativeTTL))
+	}
+
+	return rd, meta, err
+}
+
+// failureFor returns the cached failure for the key, if any.
+func (l *LoadingCache) failureFor(key string) error {
+	if l.NegativeTTL <= 0 {
+		return nil
+	}
+
+	if err := l.failures.get(key, l.now()); err != nil {
+		return &cachedFailure{err: err}
+	}
+
+	return nil
+}
+
+// defaultCacheableError considers all errors, except the context ones,
+// as cacheable.
+func defaultCacheableError(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// failureIndex keeps loader failures until their expiration.
+type failureIndex struct {
+	mu    sync.Mutex
+	items map[string]failure
+}
+
+type failure struct {
+	err   error
+	until time.Time
+}
+
+func (f *failureIndex) remember(key string, err error, until time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.items == nil {
+		f.items = map[string]failure{}
+	}
+
+	f.items[key] = failure{err: err, until: until}
+}
+
+// get returns the failure for the key, if it hasn't expired yet.
+func (f *failureIndex) get(key string, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fl, ok := f.items[key]
+	if !ok {
+		return nil
+	}
+
+	if !now.Before(fl.until) {
+		delete(f.items, key)
+		return nil
+	}
+
+	return fl.err
+}
+
+func (f *failureIndex) forget(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, key)
+}
+
+// cleanup removes expired failures.
+func (f *failureIndex) cleanup(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, fl := range f.items {
+		if !now.Before(fl.until) {
+			delete(f.items, key)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_NegativeCaching(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+	errNotFound := errors.New("origin: 404")
+	errUnavailable := errors.New("origin: 503")
+
+	prepare := func(opts ...Option) (svc *LoadingCache, loads *int64, loader func(error) Loader) {
+		svc = NewLoadingCache(NewMemory(0, NopLogger()), append(opts, WithLogger(NopLogger()))...)
+		svc.now = func() time.Time { return now }
+		loads = new(int64)
+		loader = func(err error) Loader {
+			return func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				atomic.AddInt64(loads, 1)
+				return nil, FileMeta{}, err
+			}
+		}
+		return svc, loads, loader
+	}
+
+	t.Run("failure is cached", func(t *testing.T) {
+		svc, loads, loader := prepare(WithNegativeCaching(time.Minute, nil))
+
+		_, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(errNotFound)})
+		assert.ErrorIs(t, err, errNotFound)
+		assert.False(t, errors.Is(err, ErrCachedFailure))
+
+		_, _, err = svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(errNotFound)})
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorIs(t, err, ErrCachedFailure)
+		assert.EqualError(t, err, "cached loader failure: origin: 404")
+
+		_, _, err = svc.GetURL(context.Background(), GetRequest{Key: "key", Loader: loader(errNotFound)}, GetURLParams{})
+		assert.ErrorIs(t, err, ErrCachedFailure)
+
+		assert.Equal(t, int64(1), atomic.LoadInt64(loads))
+
+		// failure expires
+		svc.now = func() time.Time { return now.Add(time.Minute) }
+		_, _, err = svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(errNotFound)})
+		assert.ErrorIs(t, err, errNotFound)
+		assert.False(t, errors.Is(err, ErrCachedFailure))
+		assert.Equal(t, int64(2), atomic.LoadInt64(loads))
+	})
+
+	t.Run("error is not cacheable", func(t *testing.T) {
+		svc, loads, loader := prepare(WithNegativeCaching(time.Minute, func(err error) bool {
+			return errors.Is(err, errNotFound)
+		}))
+
+		for i := 0; i < 2; i++ {
+			_, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(errUnavailable)})
+			assert.ErrorIs(t, err, errUnavailable)
+			assert.False(t, errors.Is(err, ErrCachedFailure))
+		}
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(loads))
+	})
+
+	t.Run("uncacheable errors are not cached", func(t *testing.T) {
+		svc, loads, loader := prepare(WithNegativeCaching(time.Minute, nil))
+
+		for i := 0; i < 2; i++ {
+			_, _, err := svc.GetFile(context.Background(), GetRequest{
+				Key:    "key",
+				Loader: loader(fmt.Errorf("origin: private response: %w", ErrUncacheable)),
+			})
+			assert.ErrorIs(t, err, ErrUncacheable)
+			assert.False(t, errors.Is(err, ErrCachedFailure))
+		}
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(loads))
+		assert.Zero(t, atomic.LoadInt64(&svc.Errors), "uncacheable result is not a failure")
+	})
+
+	t.Run("context errors are not cached by default", func(t *testing.T) {
+		svc, loads, loader := prepare(WithNegativeCaching(time.Minute, nil))
+
+		for i := 0; i < 2; i++ {
+			_, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(context.Canceled)})
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(loads))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		svc, loads, loader := prepare()
+
+		for i := 0; i < 2; i++ {
+			_, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(errNotFound)})
+			assert.ErrorIs(t, err, errNotFound)
+		}
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(loads))
+		require.Empty(t, svc.failures.items)
+	})
+}
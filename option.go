@@ -6,17 +6,103 @@ import "time"
 type GetRequest struct {
 	Key string
 	TTL time.Duration
+	// Loader loads the file on miss. If nil, miss results in ErrNotFound.
 	Loader
+
+	// Stream sets whether GetFile on miss should return the reader, which
+	// streams the content as it arrives from the loader, while the file is
+	// concurrently put into the store, instead of waiting for the put to be
+	// completed. Store errors are returned by the reader, thus the caller
+	// must read it till the end to make sure that the file has been cached.
+	// The reader must be closed.
+	Stream bool
+
+	// MaxStale sets the period after the file's expiration, during which
+	// the expired file is still served, while being reloaded in background.
+	// Loader is called with the background context in such case.
+	// Invalidation keeps the expired file till the end of the period.
+	// Zero means that expired files are loaded again on request.
+	MaxStale time.Duration
+
+	// Seekable sets whether GetFile on hit should return the reader, which
+	// implements io.Seeker and io.ReaderAt, if the store implements
+	// RangeGetter and reports the file's size. Such reader requests only the
+	// needed parts of the file, see RangeReader.
+	Seekable bool
+
+	// Tags are attached to the loaded file, so it can be removed along with
+	// other files with the same tag by LoadingCache.InvalidateTag.
+	// Tags must not contain commas.
+	Tags []string
 }
 
 // Options defines cache options.
 type Options struct {
 	Log Logger
+	// StructuredLog receives leveled log records with attributes. If set,
+	// it is used instead of Log.
+	StructuredLog StructuredLogger
+	// Metrics receives measurements of cache operations, if set.
+	Metrics Metrics
+	// Tracer starts spans of cache operations, if set.
+	Tracer Tracer
+	// Hooks receives events of the cache lifecycle, if set.
+	Hooks Hooks
 	// InvalidatePeriod sets the time for checking cache for expired items.
 	// Zero means "no invalidation", i.e. backend invalidates items by its own.
 	InvalidatePeriod time.Duration
 	// ExtendTTL sets whether cache should extend TTL of cached items on hit.
 	ExtendTTL bool
+	// SpillDir sets the directory for temporary files, which keep the content
+	// of loaded files on miss. Empty means the default directory for
+	// temporary files.
+	SpillDir string
+	// MemoryBufferThreshold sets the maximal size of the loaded file, which
+	// content is kept in memory on miss instead of a temporary file.
+	// The size is taken from FileMeta.Size, provided by the loader.
+	// Zero means that content is always kept in temporary files.
+	MemoryBufferThreshold int64
+	// StreamPutTimeout limits the duration of loading the streamed file and
+	// putting it into the store, see GetRequest.Stream. The loader and the
+	// put are not canceled along with the request's context, as the file is
+	// cached even if the caller has closed the reader early. 5 minutes are
+	// used, if not set.
+	StreamPutTimeout time.Duration
+	// MaxSize sets the maximal total size of cached files in bytes.
+	// Zero means no limit.
+	MaxSize int64
+	// MaxKeys sets the maximal number of cached files.
+	// Zero means no limit.
+	MaxKeys int
+	// EvictionPolicy defines the order, in which files are evicted, when the
+	// cache exceeds MaxSize or MaxKeys. EvictLRU is used, if not set.
+	EvictionPolicy EvictionPolicy
+	// RefreshAhead sets the fraction of the file's TTL before expiration,
+	// within which a hit reloads the file in background, so that frequently
+	// requested files never expire. The TTL is the one of the file's last
+	// load, and the loader of the last load is used, if the hit doesn't
+	// provide one. Loader is called with the background context in such
+	// case. Zero disables refreshing.
+	RefreshAhead float64
+	// RefreshWorkers limits the number of concurrent background refreshes.
+	// 4 workers are used, if not set.
+	RefreshWorkers int
+	// RevalidateWorkers limits the number of concurrent background reloads
+	// of stale files, see GetRequest.MaxStale. If all workers are busy, the
+	// file is reloaded on one of the next hits. 4 workers are used, if not
+	// set.
+	RevalidateWorkers int
+	// BatchParallelism limits the number of concurrent requests, made by
+	// GetFiles and GetURLs. 8 requests are made concurrently, if not set.
+	BatchParallelism int
+	// NegativeTTL sets the period, during which the loader's failure for
+	// the key is remembered and returned as ErrCachedFailure without calling
+	// the loader again. Zero disables caching of failures.
+	NegativeTTL time.Duration
+	// CacheableError reports whether the loader's error should be cached.
+	// All errors, except context cancellation and deadline, are cached,
+	// if not set.
+	CacheableError func(err error) bool
 }
 
 // Option is a function to apply options.
@@ -28,9 +114,108 @@ func WithLogger(log Logger) Option {
 	return func(o *Options) { o.Log = log }
 }
 
+// WithStructuredLogger sets the structured logger for cache, which is used
+// instead of the one, set by WithLogger.
+func WithStructuredLogger(log StructuredLogger) Option {
+	return func(o *Options) { o.StructuredLog = log }
+}
+
+// WithHooks sets the receiver of the cache lifecycle events.
+// Wrap hooks with NewAsyncHooks to deliver events in background.
+func WithHooks(h Hooks) Option {
+	return func(o *Options) { o.Hooks = h }
+}
+
 // WithInvalidationPeriod sets the period between cache's checks for expired
 // items. Useful for caches, like S3.
 // No manual invalidation by default.
 func WithInvalidationPeriod(period time.Duration) Option {
 	return func(o *Options) { o.InvalidatePeriod = period }
 }
+
+// WithSpillDir sets the directory for temporary files, which keep the
+// content of loaded files on miss.
+// Default directory for temporary files is used by default.
+func WithSpillDir(dir string) Option {
+	return func(o *Options) { o.SpillDir = dir }
+}
+
+// WithMemoryBufferThreshold sets the maximal size of the loaded file, which
+// content is kept in memory on miss instead of a temporary file.
+// Content is always kept in temporary files by default.
+func WithMemoryBufferThreshold(size int64) Option {
+	return func(o *Options) { o.MemoryBufferThreshold = size }
+}
+
+// WithStreamPutTimeout sets the maximal duration of loading the streamed
+// file and putting it into the store.
+// 5 minutes by default.
+func WithStreamPutTimeout(timeout time.Duration) Option {
+	return func(o *Options) { o.StreamPutTimeout = timeout }
+}
+
+// WithMaxSize sets the maximal total size of cached files in bytes.
+// No limit by default.
+func WithMaxSize(size int64) Option {
+	return func(o *Options) { o.MaxSize = size }
+}
+
+// WithMaxKeys sets the maximal number of cached files.
+// No limit by default.
+func WithMaxKeys(keys int) Option {
+	return func(o *Options) { o.MaxKeys = keys }
+}
+
+// WithEvictionPolicy sets the order, in which files are evicted, when the
+// cache exceeds its size limits.
+// EvictLRU by default.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(o *Options) { o.EvictionPolicy = policy }
+}
+
+// WithRefreshAhead enables background reloading of files, which are hit
+// within the given fraction of their TTL before expiration, with the given
+// number of concurrent workers.
+// Refreshing is disabled by default.
+func WithRefreshAhead(fraction float64, workers int) Option {
+	return func(o *Options) {
+		o.RefreshAhead = fraction
+		o.RefreshWorkers = workers
+	}
+}
+
+// WithRevalidateWorkers limits the number of concurrent background reloads
+// of stale files.
+// 4 workers by default.
+func WithRevalidateWorkers(workers int) Option {
+	return func(o *Options) { o.RevalidateWorkers = workers }
+}
+
+// WithNegativeCaching enables caching of loader failures for the given
+// period. cacheable reports whether the particular error should be cached,
+// nil means all errors, except context ones.
+// Failures are not cached by default.
+func WithNegativeCaching(ttl time.Duration, cacheable func(err error) bool) Option {
+	return func(o *Options) {
+		o.NegativeTTL = ttl
+		o.CacheableError = cacheable
+	}
+}
+
+// WithMetrics sets the receiver of cache operations' measurements.
+// No metrics are reported by default.
+func WithMetrics(m Metrics) Option {
+	return func(o *Options) { o.Metrics = m }
+}
+
+// WithTracer sets the tracer of cache operations.
+// No spans are started by default.
+func WithTracer(t Tracer) Option {
+	return func(o *Options) { o.Tracer = t }
+}
+
+// WithBatchParallelism limits the number of concurrent requests, made by
+// GetFiles and GetURLs.
+func WithBatchParallelism(n int) Option {
+	return func(o *Options) { o.BatchParallelism = n }
+}
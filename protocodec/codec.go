@@ -0,0 +1,55 @@
+// Package protocodec provides the protobuf codec for fcache.TypedCache.
+package protocodec
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Semior001/fcache"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec implements fcache.Codec for protobuf messages.
+// T must be a pointer to the generated message type, e.g. *pb.User.
+type Codec[T proto.Message] struct{}
+
+var _ fcache.Codec[proto.Message] = Codec[proto.Message]{}
+
+// Encode implements fcache.Codec.
+func (Codec[T]) Encode(w io.Writer, v T) error {
+	b, err := proto.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal protobuf: %w", err)
+	}
+
+	if _, err = w.Write(b); err != nil {
+		return fmt.Errorf("write protobuf: %w", err)
+	}
+
+	return nil
+}
+
+// Decode implements fcache.Codec.
+func (Codec[T]) Decode(r io.Reader) (T, error) {
+	var zero T
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return zero, fmt.Errorf("read protobuf: %w", err)
+	}
+
+	// generated messages return the valid reflection even for nil pointers
+	v, ok := zero.ProtoReflect().New().Interface().(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected message type %T", zero)
+	}
+
+	if err = proto.Unmarshal(b, v); err != nil {
+		return zero, fmt.Errorf("unmarshal protobuf: %w", err)
+	}
+
+	return v, nil
+}
+
+// Mime implements fcache.Codec.
+func (Codec[T]) Mime() string { return "application/x-protobuf" }
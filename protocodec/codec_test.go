@@ -0,0 +1,46 @@
+package protocodec
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	codec := Codec[*wrapperspb.StringValue]{}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, codec.Encode(buf, wrapperspb.String("value")))
+
+	v, err := codec.Decode(buf)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(wrapperspb.String("value"), v))
+
+	_, err = codec.Decode(bytes.NewReader([]byte{0xff}))
+	assert.Error(t, err)
+}
+
+func TestCodec_TypedCache(t *testing.T) {
+	svc := fcache.NewLoadingCache(fcache.NewMemory(0, fcache.NopLogger()), fcache.WithLogger(fcache.NopLogger()))
+	typed := fcache.NewTypedCache[*wrapperspb.StringValue](svc, Codec[*wrapperspb.StringValue]{})
+
+	for i := 0; i < 2; i++ {
+		v, err := typed.Get(context.Background(), fcache.TypedRequest[*wrapperspb.StringValue]{
+			Key: "key",
+			TTL: time.Hour,
+			Loader: func(ctx context.Context) (*wrapperspb.StringValue, error) {
+				require.Zero(t, i, "must be loaded once")
+				return wrapperspb.String("value"), nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "value", v.GetValue())
+	}
+}
@@ -0,0 +1,148 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RangeReader reads the file from the store, which implements RangeGetter.
+// It implements io.ReadSeekCloser and io.ReaderAt, each seek leads to
+// a new request to the store on the next read. ReadAt requests only the
+// needed part of the file and is safe for concurrent use, while other
+// methods are not.
+type RangeReader struct {
+	ctx  context.Context
+	rg   RangeGetter
+	key  string
+	size int64
+
+	offset int64
+	rd     io.ReadCloser // reader, started at offset
+}
+
+// NewRangeReader makes new instance of RangeReader for the file of the
+// given size. The context is used for all requests to the store.
+func NewRangeReader(ctx context.Context, rg RangeGetter, key string, size int64) *RangeReader {
+	return &RangeReader{ctx: ctx, rg: rg, key: key, size: size}
+}
+
+// Size returns the size of the file.
+func (r *RangeReader) Size() int64 { return r.size }
+
+// Read implements io.Reader.
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.rd == nil {
+		rd, err := r.rg.GetRange(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, fmt.Errorf("get range from store: %w", err)
+		}
+		r.rd = rd
+	}
+
+	n, err := r.rd.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset {
+		if err := r.closeReader(); err != nil {
+			return 0, err
+		}
+		r.offset = offset
+	}
+
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	length := int64(len(p))
+	if rest := r.size - off; length > rest {
+		length = rest
+	}
+
+	rd, err := r.rg.GetRange(r.ctx, r.key, off, length)
+	if err != nil {
+		return 0, fmt.Errorf("get range from store: %w", err)
+	}
+	defer func() { _ = rd.Close() }()
+
+	n, err := io.ReadFull(rd, p[:length])
+	if err == nil && length < int64(len(p)) {
+		err = io.EOF
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close closes the current reader, if any.
+func (r *RangeReader) Close() error { return r.closeReader() }
+
+func (r *RangeReader) closeReader() error {
+	if r.rd == nil {
+		return nil
+	}
+
+	err := r.rd.Close()
+	r.rd = nil
+	if err != nil {
+		return fmt.Errorf("close range reader: %w", err)
+	}
+	return nil
+}
+
+// openFile returns the reader of the cached file. The seekable reader is
+// returned, if it is requested, the store implements RangeGetter and the
+// size of the file is known.
+func (l *LoadingCache) openFile(ctx context.Context, req GetRequest, meta FileMeta) (rd io.ReadCloser, err error) {
+	if rg, ok := l.Store.(RangeGetter); ok && req.Seekable && meta.Size > 0 {
+		// parts of the file are requested on reads
+		return NewRangeReader(ctx, rg, req.Key, meta.Size), nil
+	}
+
+	err = l.traced(ctx, "fcache.Store.Get", req.Key, func(ctx context.Context) (err error) {
+		rd, err = l.Store.Get(ctx, req.Key)
+		return err
+	})
+	return rd, err
+}
+
+// sectionReader returns the reader of length bytes from the offset.
+// Negative length means "till the end".
+func sectionReader(rd io.ReaderAt, offset, length int64) io.Reader {
+	if length < 0 {
+		length = 1<<63 - 1 - offset
+	}
+	return io.NewSectionReader(rd, offset, length)
+}
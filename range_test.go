@@ -0,0 +1,143 @@
+package fcache
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_GetRange(t *testing.T) {
+	fs := newTestFS(t, time.Now())
+
+	for name, store := range map[string]interface {
+		Store
+		RangeGetter
+	}{"fs": fs, "memory": NewMemory(0, NopLogger())} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, store.Put(ctx, "key", FileMeta{Size: 10}, stringReader("0123456789")))
+
+			tbl := []struct {
+				offset, length int64
+				expected       string
+			}{
+				{offset: 0, length: -1, expected: "0123456789"},
+				{offset: 3, length: -1, expected: "3456789"},
+				{offset: 3, length: 4, expected: "3456"},
+				{offset: 8, length: 10, expected: "89"},
+				{offset: 12, length: 1, expected: ""},
+				{offset: 0, length: 0, expected: ""},
+			}
+
+			for _, tt := range tbl {
+				rd, err := store.GetRange(ctx, "key", tt.offset, tt.length)
+				require.NoError(t, err)
+				assertReader(t, rd, tt.expected)
+			}
+
+			_, err := store.GetRange(ctx, "missing", 0, -1)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestRangeReader(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory(0, NopLogger())
+	require.NoError(t, store.Put(ctx, "key", FileMeta{Size: 10}, stringReader("0123456789")))
+
+	rd := NewRangeReader(ctx, store, "key", 10)
+	assert.Equal(t, int64(10), rd.Size())
+
+	buf := make([]byte, 3)
+	n, err := io.ReadFull(rd, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "012", string(buf[:n]))
+
+	pos, err := rd.Seek(2, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), pos)
+	n, err = io.ReadFull(rd, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "567", string(buf[:n]))
+
+	pos, err = rd.Seek(-1, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), pos)
+	rest, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	assert.Equal(t, "9", string(rest))
+
+	_, err = rd.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+
+	n, err = rd.ReadAt(buf, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "123", string(buf[:n]))
+
+	n, err = rd.ReadAt(buf, 8)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "89", string(buf[:n]))
+
+	_, err = rd.ReadAt(buf, 10)
+	assert.ErrorIs(t, err, io.EOF)
+
+	require.NoError(t, rd.Close())
+}
+
+func TestLoadingCache_GetFile_Seekable(t *testing.T) {
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()), WithMetrics(&metricsRecorder{
+		counts: map[string]int{},
+	}))
+	req := GetRequest{Key: "key", TTL: time.Hour, Loader: stringLoader("0123456789"), Seekable: true}
+
+	rd, _, err := svc.GetFile(context.Background(), req)
+	require.NoError(t, err)
+	assertReader(t, rd, "0123456789")
+
+	rd, _, err = svc.GetFile(context.Background(), req)
+	require.NoError(t, err)
+
+	rs, ok := rd.(interface {
+		io.ReadSeeker
+		io.ReaderAt
+	})
+	require.True(t, ok, "reader must be seekable")
+
+	_, err = rs.Seek(7, io.SeekStart)
+	require.NoError(t, err)
+	assertReader(t, rd, "789")
+
+	buf := make([]byte, 2)
+	_, err = rs.ReadAt(buf, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "45", string(buf))
+}
+
+func TestLoadingCache_GetFile_SeekableUnknownSize(t *testing.T) {
+	store := unknownSizeStore{Memory: NewMemory(0, NopLogger())}
+	svc := NewLoadingCache(store, WithLogger(NopLogger()))
+	req := GetRequest{Key: "key", TTL: time.Hour, Loader: stringLoader("0123456789"), Seekable: true}
+
+	rd, _, err := svc.GetFile(context.Background(), req)
+	require.NoError(t, err)
+	assertReader(t, rd, "0123456789")
+
+	// the whole file is read, as its size is unknown
+	rd, _, err = svc.GetFile(context.Background(), req)
+	require.NoError(t, err)
+	assertReader(t, rd, "0123456789")
+}
+
+// unknownSizeStore doesn't report sizes of files.
+type unknownSizeStore struct{ *Memory }
+
+func (s unknownSizeStore) Meta(ctx context.Context, key string) (FileMeta, error) {
+	meta, err := s.Memory.Meta(ctx, key)
+	meta.Size = 0
+	return meta, err
+}
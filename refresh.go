@@ -0,0 +1,110 @@
+package fcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultRefreshWorkers = 4
+
+// refreshAhead reloads the file in background, if it has been hit within
+// the configured fraction of its TTL before expiration. The TTL is the
+// effective one of the last load, so files with the expiration, set by the
+// loader, are refreshed too. Hits without the loader reload the file with
+// the request, which has loaded it last time. Refreshes are bounded by the
+// worker pool, if all workers are busy, the refresh is skipped and will be
+// triggered by one of the next hits.
+func (l *LoadingCache) refreshAhead(req GetRequest, remaining time.Duration) {
+	if l.RefreshAhead <= 0 || l.refreshSem == nil {
+		return
+	}
+
+	info, ok := l.refreshes.get(req.Key)
+	if !ok || remaining > time.Duration(l.RefreshAhead*float64(info.ttl)) {
+		return
+	}
+
+	if req.Loader == nil {
+		req = info.req
+	}
+
+	select {
+	case l.refreshSem <- struct{}{}:
+	default:
+		return // all workers are busy
+	}
+
+	c, leader := l.inflight.join(req.Key)
+	if !leader {
+		l.inflight.leave(c)
+		<-l.refreshSem
+		return
+	}
+
+	started := l.background(func(ctx context.Context) {
+		defer func() { <-l.refreshSem }()
+		l.reload(ctx, req, c, "soon-to-expire")
+	})
+	if !started {
+		<-l.refreshSem
+		l.inflight.finish(req.Key, c, FileMeta{}, nil, errShutdown)
+	}
+}
+
+// loaded remembers the request, which has loaded the file, along with the
+// file's effective TTL to refresh it ahead of expiration.
+func (l *LoadingCache) loaded(req GetRequest, meta FileMeta) {
+	if l.RefreshAhead <= 0 {
+		return
+	}
+
+	expiresAt, ok := meta.ExpiresAt()
+	if !ok {
+		return
+	}
+
+	ttl := expiresAt.Sub(l.now())
+	if ttl <= 0 {
+		return
+	}
+
+	req = GetRequest{Key: req.Key, TTL: req.TTL, Loader: req.Loader, Tags: req.Tags}
+	l.refreshes.remember(req, ttl)
+}
+
+// refreshIndex keeps the requests, which have loaded the files, to refresh
+// the files in background.
+type refreshIndex struct {
+	mu    sync.Mutex
+	items map[string]refreshInfo
+}
+
+type refreshInfo struct {
+	req GetRequest
+	ttl time.Duration // effective TTL of the loaded file
+}
+
+func (r *refreshIndex) remember(req GetRequest, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.items == nil {
+		r.items = map[string]refreshInfo{}
+	}
+
+	r.items[req.Key] = refreshInfo{req: req, ttl: ttl}
+}
+
+func (r *refreshIndex) get(key string) (refreshInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, ok := r.items[key]
+	return info, ok
+}
+
+func (r *refreshIndex) forget(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.items, key)
+}
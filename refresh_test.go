@@ -0,0 +1,133 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_RefreshAhead(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	prepare := func(t *testing.T, workers int, keys ...string) (*LoadingCache, *Memory) {
+		store := NewMemory(0, NopLogger())
+		svc := NewLoadingCache(store, WithLogger(NopLogger()), WithRefreshAhead(0.2, workers))
+		svc.now = func() time.Time { return now }
+
+		for _, key := range keys {
+			rd, _, err := svc.GetFile(context.Background(), GetRequest{
+				Key:    key,
+				TTL:    10 * time.Minute,
+				Loader: stringLoader("old data"),
+			})
+			require.NoError(t, err)
+			require.NoError(t, rd.Close())
+		}
+
+		return svc, store
+	}
+
+	t.Run("hit before refresh window", func(t *testing.T) {
+		svc, store := prepare(t, 1, "key")
+		svc.now = func() time.Time { return now.Add(7 * time.Minute) }
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			TTL:    10 * time.Minute,
+			Loader: stringLoader("new data"),
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+
+		svc.bg.Wait()
+		assertMemoryContent(t, store, "key", "old data")
+	})
+
+	t.Run("hit within refresh window", func(t *testing.T) {
+		svc, store := prepare(t, 1, "key")
+		svc.now = func() time.Time { return now.Add(9 * time.Minute) }
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			TTL:    10 * time.Minute,
+			Loader: stringLoader("new data"),
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+
+		svc.bg.Wait()
+		assertMemoryContent(t, store, "key", "new data")
+
+		meta, err := store.Meta(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(19*time.Minute).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, svc.CacheStats)
+	})
+
+	t.Run("hit without loader", func(t *testing.T) {
+		svc, store := prepare(t, 1, "key")
+		svc.now = func() time.Time { return now.Add(9 * time.Minute) }
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key"})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+
+		svc.bg.Wait()
+		meta, err := store.Meta(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(19*time.Minute).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+	})
+
+	t.Run("expiration set by loader", func(t *testing.T) {
+		svc, store := prepare(t, 1)
+
+		var loads int64
+		loader := func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			n := atomic.AddInt64(&loads, 1)
+			meta := FileMeta{}.WithExpiration(svc.now().Add(10 * time.Minute))
+			return io.NopCloser(strings.NewReader(fmt.Sprintf("data %d", n))), meta, nil
+		}
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader})
+		require.NoError(t, err)
+		assertReader(t, rd, "data 1")
+
+		svc.now = func() time.Time { return now.Add(9 * time.Minute) }
+		rd, _, err = svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader})
+		require.NoError(t, err)
+		assertReader(t, rd, "data 1")
+
+		svc.bg.Wait()
+		assertMemoryContent(t, store, "key", "data 2")
+	})
+
+	t.Run("workers are busy", func(t *testing.T) {
+		svc, _ := prepare(t, 1, "key-1", "key-2")
+		svc.now = func() time.Time { return now.Add(9 * time.Minute) }
+
+		release := make(chan struct{})
+		var loads int64
+		loader := func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			atomic.AddInt64(&loads, 1)
+			<-release
+			return io.NopCloser(strings.NewReader("new data")), FileMeta{}, nil
+		}
+
+		for _, key := range []string{"key-1", "key-1", "key-2"} {
+			rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: key, TTL: 10 * time.Minute, Loader: loader})
+			require.NoError(t, err)
+			assertReader(t, rd, "old data")
+		}
+
+		close(release)
+		svc.bg.Wait()
+		assert.Equal(t, int64(1), atomic.LoadInt64(&loads))
+	})
+}
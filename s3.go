@@ -36,25 +36,44 @@ type s3client interface {
 
 // S3 implements Cache for S3.
 type S3 struct {
-	log Logger
-	cl  s3client
+	log    Logger
+	cl     s3client
+	tracer Tracer
 
 	bucket string
 	prefix string
 }
 
+// S3Option customizes S3.
+type S3Option func(s *S3)
+
+// WithS3Tracer sets the tracer of requests to S3.
+// No spans are started by default.
+func WithS3Tracer(t Tracer) S3Option {
+	return func(s *S3) { s.tracer = t }
+}
+
 // NewS3 makes new instance of S3.
-func NewS3(cl *minio.Client, bucket, prefix string, log Logger) *S3 {
-	return &S3{
+func NewS3(cl *minio.Client, bucket, prefix string, log Logger, opts ...S3Option) *S3 {
+	res := &S3{
 		log:    log,
 		cl:     cl,
 		bucket: bucket,
 		prefix: prefix,
 	}
+
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	return res
 }
 
 // Meta returns meta information about the file at underlying key.
-func (s *S3) Meta(ctx context.Context, key string) (FileMeta, error) {
+func (s *S3) Meta(ctx context.Context, key string) (_ FileMeta, err error) {
+	ctx, span := s.startSpan(ctx, "s3.StatObject", key)
+	defer func() { endSpan(span, err) }()
+
 	var errResp minio.ErrorResponse
 
 	oi, err := s.cl.StatObject(ctx, s.bucket, s.key(key), minio.StatObjectOptions{})
@@ -69,7 +88,10 @@ func (s *S3) Meta(ctx context.Context, key string) (FileMeta, error) {
 }
 
 // UpdateMeta updates meta information about the file at underlying key.
-func (s *S3) UpdateMeta(ctx context.Context, key string, meta FileMeta) error {
+func (s *S3) UpdateMeta(ctx context.Context, key string, meta FileMeta) (err error) {
+	ctx, span := s.startSpan(ctx, "s3.CopyObject", key)
+	defer func() { endSpan(span, err) }()
+
 	if meta.Meta == nil {
 		meta.Meta = map[string]string{}
 	}
@@ -82,7 +104,7 @@ func (s *S3) UpdateMeta(ctx context.Context, key string, meta FileMeta) error {
 		UserMetadata:    meta.Meta,
 	}
 
-	_, err := s.cl.CopyObject(ctx, destOpts, minio.CopySrcOptions{Bucket: s.bucket, Object: s.key(key)})
+	_, err = s.cl.CopyObject(ctx, destOpts, minio.CopySrcOptions{Bucket: s.bucket, Object: s.key(key)})
 	if err != nil {
 		return fmt.Errorf("copy object to itself: %w", err)
 	}
@@ -94,7 +116,10 @@ func (s *S3) UpdateMeta(ctx context.Context, key string, meta FileMeta) error {
 // NOTE: if file under this key is not present in S3, this method WILL NOT
 // return a NotFound error, instead, it will return a reader, which will return
 // error when there will be an attempt to read.
-func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+func (s *S3) Get(ctx context.Context, key string) (_ io.ReadCloser, err error) {
+	ctx, span := s.startSpan(ctx, "s3.GetObject", key)
+	defer func() { endSpan(span, err) }()
+
 	obj, err := s.cl.GetObject(ctx, s.bucket, s.key(key), minio.GetObjectOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("s3 returned error: %w", err)
@@ -103,8 +128,40 @@ func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
 	return obj, nil
 }
 
+// GetRange returns the reader of the file's part.
+// NOTE: as for Get, absence of the file is reported by the reader.
+func (s *S3) GetRange(ctx context.Context, key string, offset, length int64) (_ io.ReadCloser, err error) {
+	ctx, span := s.startSpan(ctx, "s3.GetObject", key, Int64Attr("s3.offset", offset), Int64Attr("s3.length", length))
+	defer func() { endSpan(span, err) }()
+
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	switch {
+	case length > 0:
+		err = opts.SetRange(offset, offset+length-1)
+	case offset > 0:
+		err = opts.SetRange(offset, 0)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("set range: %w", err)
+	}
+
+	obj, err := s.cl.GetObject(ctx, s.bucket, s.key(key), opts)
+	if err != nil {
+		return nil, fmt.Errorf("s3 returned error: %w", err)
+	}
+
+	return obj, nil
+}
+
 // GetURL returns the URL from the cache backend.
-func (s *S3) GetURL(ctx context.Context, key string, params GetURLParams) (string, error) {
+func (s *S3) GetURL(ctx context.Context, key string, params GetURLParams) (_ string, err error) {
+	ctx, span := s.startSpan(ctx, "s3.PresignedGetObject", key)
+	defer func() { endSpan(span, err) }()
+
 	var errResp minio.ErrorResponse
 
 	oi, err := s.cl.StatObject(ctx, s.bucket, s.key(key), minio.StatObjectOptions{})
@@ -130,7 +187,10 @@ func (s *S3) GetURL(ctx context.Context, key string, params GetURLParams) (strin
 }
 
 // Put puts file into S3.
-func (s *S3) Put(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+func (s *S3) Put(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) (err error) {
+	ctx, span := s.startSpan(ctx, "s3.PutObject", key, Int64Attr(AttrSize, meta.Size))
+	defer func() { endSpan(span, err) }()
+
 	defer func() {
 		if err := rd.Close(); err != nil {
 			s.log.Printf("[WARN] failed to close reader: %v", err)
@@ -153,15 +213,18 @@ func (s *S3) Put(ctx context.Context, key string, meta FileMeta, rd io.ReadClose
 }
 
 // Remove removes file by its key.
-func (s *S3) Remove(ctx context.Context, key string) error {
+func (s *S3) Remove(ctx context.Context, key string) (err error) {
+	ctx, span := s.startSpan(ctx, "s3.RemoveObject", key)
+	defer func() { endSpan(span, err) }()
+
 	var errResp minio.ErrorResponse
 
-	err := s.cl.RemoveObject(ctx, s.bucket, s.key(key), minio.RemoveObjectOptions{})
+	err = s.cl.RemoveObject(ctx, s.bucket, s.key(key), minio.RemoveObjectOptions{})
 	if errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound {
 		return ErrNotFound
 	}
 	if err != nil {
-		return fmt.Errorf("s3 returned")
+		return fmt.Errorf("s3 returned error: %w", err)
 	}
 
 	return nil
@@ -169,7 +232,10 @@ func (s *S3) Remove(ctx context.Context, key string) error {
 
 // Stat returns cache stats.
 func (s *S3) Stat(ctx context.Context) (res StoreStats, err error) {
-	ch := s.cl.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key("")})
+	ctx, span := s.startSpan(ctx, "s3.ListObjects", "")
+	defer func() { endSpan(span, err, Int64Attr(AttrCount, int64(res.Keys))) }()
+
+	ch := s.cl.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key(""), Recursive: true})
 
 	for obj := range ch {
 		if obj.Err != nil {
@@ -185,9 +251,20 @@ func (s *S3) Stat(ctx context.Context) (res StoreStats, err error) {
 
 // List lists object in S3 bucket.
 func (s *S3) List(ctx context.Context) ([]FileMeta, error) {
-	var result []FileMeta
+	return s.ListPrefix(ctx, "")
+}
+
+// ListPrefix lists objects in S3 bucket with keys, starting with the
+// given prefix.
+func (s *S3) ListPrefix(ctx context.Context, prefix string) (result []FileMeta, err error) {
+	ctx, span := s.startSpan(ctx, "s3.ListObjects", prefix)
+	defer func() { endSpan(span, err, Int64Attr(AttrCount, int64(len(result)))) }()
 
-	ch := s.cl.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{WithMetadata: true, Prefix: s.key("")})
+	ch := s.cl.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		WithMetadata: true,
+		Prefix:       s.key(prefix),
+		Recursive:    true,
+	})
 	for obj := range ch {
 		if obj.Err != nil {
 			return nil, fmt.Errorf("s3 returned error: %w", obj.Err)
@@ -199,10 +276,12 @@ func (s *S3) List(ctx context.Context) ([]FileMeta, error) {
 }
 
 // Keys returns all keys, present in cache.
-func (s *S3) Keys(ctx context.Context) ([]string, error) {
-	ch := s.cl.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key("")})
+func (s *S3) Keys(ctx context.Context) (res []string, err error) {
+	ctx, span := s.startSpan(ctx, "s3.ListObjects", "")
+	defer func() { endSpan(span, err, Int64Attr(AttrCount, int64(len(res)))) }()
+
+	ch := s.cl.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key(""), Recursive: true})
 
-	var res []string
 	for obj := range ch {
 		if obj.Err != nil {
 			return nil, fmt.Errorf("list objects: %w", obj.Err)
@@ -214,6 +293,18 @@ func (s *S3) Keys(ctx context.Context) ([]string, error) {
 	return res, nil
 }
 
+// startSpan starts the span of the request to S3, attributed with the
+// bucket and the object's key.
+func (s *S3) startSpan(ctx context.Context, name, key string, attrs ...Attr) (context.Context, Span) {
+	tracer := s.tracer
+	if tracer == nil {
+		tracer = nopTracer{}
+	}
+
+	attrs = append(attrs, StringAttr("s3.bucket", s.bucket), StringAttr("s3.key", s.key(key)))
+	return tracer.Start(ctx, name, attrs...)
+}
+
 func (s *S3) key(key string) string {
 	if s.prefix == "" {
 		return key
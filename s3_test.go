@@ -72,6 +72,50 @@ func TestS3_Get(t *testing.T) {
 	})
 }
 
+func TestS3_GetRange(t *testing.T) {
+	tbl := []struct {
+		name           string
+		offset, length int64
+		expectedRange  string
+	}{
+		{name: "whole file", offset: 0, length: -1, expectedRange: ""},
+		{name: "till the end", offset: 5, length: -1, expectedRange: "bytes=5-"},
+		{name: "part", offset: 5, length: 10, expectedRange: "bytes=5-14"},
+		{name: "first byte", offset: 0, length: 1, expectedRange: "bytes=0-0"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &minio.Object{}
+			svc := &S3{
+				bucket: "bucket",
+				prefix: "prefix",
+				cl: &s3clientMock{
+					GetObjectFunc: func(ctx context.Context,
+						bkt, key string,
+						opts minio.GetObjectOptions,
+					) (*minio.Object, error) {
+						assert.Equal(t, "bucket", bkt)
+						assert.Equal(t, "prefix!!key", key)
+						assert.Equal(t, tt.expectedRange, opts.Header().Get("Range"))
+						return obj, nil
+					},
+				},
+			}
+			ro, err := svc.GetRange(context.Background(), "key", tt.offset, tt.length)
+			require.NoError(t, err)
+			assert.True(t, obj == ro)
+		})
+	}
+
+	t.Run("empty range", func(t *testing.T) {
+		svc := &S3{cl: &s3clientMock{}}
+		rd, err := svc.GetRange(context.Background(), "key", 5, 0)
+		require.NoError(t, err)
+		assertReader(t, rd, "")
+	})
+}
+
 func TestS3_GetURL(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		now := time.Now()
@@ -177,7 +221,7 @@ func TestS3_Stat(t *testing.T) {
 		svc := &S3{
 			cl: &s3clientMock{
 				ListObjectsFunc: func(ctx context.Context, bkt string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
-					assert.Equal(t, minio.ListObjectsOptions{Prefix: "prefix!!"}, opts)
+					assert.Equal(t, minio.ListObjectsOptions{Prefix: "prefix!!", Recursive: true}, opts)
 					assert.Equal(t, "bucket", bkt)
 					ch := make(chan minio.ObjectInfo, 2)
 					ch <- minio.ObjectInfo{Size: 12}
@@ -201,7 +245,7 @@ func TestS3_List(t *testing.T) {
 		svc := &S3{
 			cl: &s3clientMock{
 				ListObjectsFunc: func(ctx context.Context, bkt string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
-					assert.Equal(t, minio.ListObjectsOptions{WithMetadata: true, Prefix: "prefix!!"}, opts)
+					assert.Equal(t, minio.ListObjectsOptions{WithMetadata: true, Prefix: "prefix!!", Recursive: true}, opts)
 					assert.Equal(t, "bucket", bkt)
 					ch := make(chan minio.ObjectInfo, 2)
 					ch <- minio.ObjectInfo{
@@ -253,7 +297,7 @@ func TestS3_Keys(t *testing.T) {
 		svc := &S3{
 			cl: &s3clientMock{
 				ListObjectsFunc: func(ctx context.Context, bkt string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
-					assert.Equal(t, minio.ListObjectsOptions{Prefix: "prefix!!"}, opts)
+					assert.Equal(t, minio.ListObjectsOptions{Prefix: "prefix!!", Recursive: true}, opts)
 					assert.Equal(t, "bucket", bkt)
 					ch := make(chan minio.ObjectInfo, 2)
 					ch <- minio.ObjectInfo{Key: "prefix!!key-1"}
@@ -270,3 +314,40 @@ func TestS3_Keys(t *testing.T) {
 		assert.Equal(t, []string{"key-1", "key-2"}, keys)
 	})
 }
+
+func TestS3_ListPrefix(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		now := time.Now()
+		svc := &S3{
+			cl: &s3clientMock{
+				ListObjectsFunc: func(ctx context.Context, bkt string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+					assert.Equal(t, minio.ListObjectsOptions{
+						WithMetadata: true,
+						Prefix:       "prefix!!customer/1/",
+						Recursive:    true,
+					}, opts)
+					assert.Equal(t, "bucket", bkt)
+					ch := make(chan minio.ObjectInfo, 1)
+					ch <- minio.ObjectInfo{
+						UserMetadata: map[string]string{amzMetaPrefix + metaTagsKey: "thumbnail"},
+						Size:         12,
+						Key:          "prefix!!customer/1/a.png",
+						LastModified: now,
+					}
+					close(ch)
+					return ch
+				},
+			},
+			bucket: "bucket",
+			prefix: "prefix",
+		}
+		objs, err := svc.ListPrefix(context.Background(), "customer/1/")
+		require.NoError(t, err)
+		assert.Equal(t, []FileMeta{{
+			Meta:      map[string]string{metaTagsKey: "thumbnail"},
+			Size:      12,
+			Key:       "customer/1/a.png",
+			CreatedAt: now,
+		}}, objs)
+	})
+}
@@ -0,0 +1,487 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const defaultVirtualNodes = 100
+
+// ErrNoShards is returned by Sharded, when it has no shards.
+var ErrNoShards = errors.New("no shards")
+
+// Sharded implements Store by distributing files across several stores
+// with consistent hashing. Each shard is placed on the hash ring as
+// a number of virtual nodes, derived from its name, so the distribution
+// is stable across restarts, as long as the shards have the same names.
+//
+// When a shard is added, only the keys, which now belong to it, must be
+// moved, which is done by Rebalance. Until then files are looked up
+// at their previous shards too.
+type Sharded struct {
+	log          Logger
+	virtualNodes int
+
+	keys keyLocks // serializes puts, removals and moves of the same key
+
+	mu     sync.RWMutex
+	shards map[string]Store
+	ring   hashRing
+	prev   []hashRing // rings before adding shards, until rebalanced
+}
+
+// ShardedOption customizes Sharded.
+type ShardedOption func(s *Sharded)
+
+// WithVirtualNodes sets the number of virtual nodes of each shard on the
+// hash ring. More nodes make the distribution more even.
+// 100 nodes by default.
+func WithVirtualNodes(n int) ShardedOption {
+	return func(s *Sharded) { s.virtualNodes = n }
+}
+
+// NewSharded makes new instance of Sharded over the stores by their names.
+func NewSharded(shards map[string]Store, log Logger, opts ...ShardedOption) *Sharded {
+	res := &Sharded{log: log, virtualNodes: defaultVirtualNodes, shards: map[string]Store{}}
+
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	names := make([]string, 0, len(shards))
+	for name, store := range shards {
+		res.shards[name] = store
+		names = append(names, name)
+	}
+	res.ring = newHashRing(names, res.virtualNodes)
+
+	return res
+}
+
+// AddShard adds the store as a new shard. Files, which now belong to it,
+// are still read from their previous shards, until Rebalance moves them.
+func (s *Sharded) AddShard(name string, store Store) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shards[name]; ok {
+		return fmt.Errorf("shard %q already exists", name)
+	}
+
+	s.shards[name] = store
+	s.prev = append(s.prev, s.ring)
+	s.ring = newHashRing(append(s.ring.names(), name), s.virtualNodes)
+
+	return nil
+}
+
+// Rebalance moves files, which don't belong to the shards, where they
+// are stored, to their shards, e.g. after adding a shard. It returns the
+// number of moved files.
+func (s *Sharded) Rebalance(ctx context.Context) (moved int, err error) {
+	s.mu.RLock()
+	shards := make(map[string]Store, len(s.shards))
+	for name, store := range s.shards {
+		shards[name] = store
+	}
+	rebalanced := len(s.prev)
+	s.mu.RUnlock()
+
+	errs := &multierror.Error{}
+	for name, store := range shards {
+		files, err := store.List(ctx)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("list files of shard %q: %w", name, err))
+			continue
+		}
+
+		for _, file := range files {
+			if err = ctx.Err(); err != nil {
+				return moved, err
+			}
+
+			owner, dst := s.owner(file.Key)
+			if owner == name {
+				continue
+			}
+
+			if err = s.move(ctx, file.Key, store, dst); err != nil {
+				s.log.Printf("[WARN] failed to move file %s from shard %q to %q: %v", file.Key, name, owner, err)
+				errs = multierror.Append(errs, fmt.Errorf("move %s from shard %q to %q: %w", file.Key, name, owner, err))
+				continue
+			}
+
+			moved++
+		}
+	}
+
+	if err = errs.ErrorOrNil(); err != nil {
+		return moved, err
+	}
+
+	// shards might be added during rebalancing
+	s.mu.Lock()
+	s.prev = s.prev[rebalanced:]
+	s.mu.Unlock()
+
+	return moved, nil
+}
+
+// Meta returns meta information about the file at underlying key.
+func (s *Sharded) Meta(ctx context.Context, key string) (FileMeta, error) {
+	store, err := s.lookup(ctx, key)
+	if err != nil {
+		return FileMeta{}, err
+	}
+
+	return store.Meta(ctx, key)
+}
+
+// UpdateMeta updates meta information about the file at underlying key.
+func (s *Sharded) UpdateMeta(ctx context.Context, key string, meta FileMeta) error {
+	store, err := s.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return store.UpdateMeta(ctx, key, meta)
+}
+
+// Get returns the reader of the file's content.
+func (s *Sharded) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	store, err := s.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Get(ctx, key)
+}
+
+// GetRange returns the reader of the file's part.
+func (s *Sharded) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	store, err := s.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return getRange(ctx, store, key, offset, length)
+}
+
+// GetURL returns the URL of the file from its shard.
+func (s *Sharded) GetURL(ctx context.Context, key string, params GetURLParams) (string, error) {
+	store, err := s.lookup(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return store.GetURL(ctx, key, params)
+}
+
+// Put puts file into its shard. The copy of the file, which is not moved
+// by Rebalance yet, is removed from its previous shard.
+func (s *Sharded) Put(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+	_, store := s.owner(key)
+	if store == nil {
+		_ = rd.Close()
+		return ErrNoShards
+	}
+
+	unlock := s.keys.lock(key)
+	defer unlock()
+
+	if err := store.Put(ctx, key, meta, rd); err != nil {
+		return err
+	}
+
+	for _, prev := range s.previous(key) {
+		if err := prev.Remove(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("remove stale file from previous shard: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Remove removes file from its shard and from its previous shards, if it
+// hasn't been moved yet.
+func (s *Sharded) Remove(ctx context.Context, key string) error {
+	_, store := s.owner(key)
+	if store == nil {
+		return ErrNoShards
+	}
+
+	unlock := s.keys.lock(key)
+	defer unlock()
+
+	res := ErrNotFound
+	for _, st := range append([]Store{store}, s.previous(key)...) {
+		err := st.Remove(ctx, key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return err
+		default:
+			res = nil
+		}
+	}
+
+	return res
+}
+
+// Stat returns the sum of stats of all shards.
+// Files, which are being moved by Rebalance, might be counted twice.
+func (s *Sharded) Stat(ctx context.Context) (StoreStats, error) {
+	var res StoreStats
+
+	for name, store := range s.snapshot() {
+		stat, err := store.Stat(ctx)
+		if err != nil {
+			return StoreStats{}, fmt.Errorf("get stats of shard %q: %w", name, err)
+		}
+
+		res.Keys += stat.Keys
+		res.Size += stat.Size
+	}
+
+	return res, nil
+}
+
+// Keys returns keys of all shards.
+func (s *Sharded) Keys(ctx context.Context) ([]string, error) {
+	var res []string
+	seen := map[string]bool{}
+
+	for name, store := range s.snapshot() {
+		keys, err := store.Keys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get keys of shard %q: %w", name, err)
+		}
+
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				res = append(res, key)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// List lists files of all shards.
+func (s *Sharded) List(ctx context.Context) ([]FileMeta, error) {
+	return s.list(func(store Store) ([]FileMeta, error) { return store.List(ctx) })
+}
+
+// ListPrefix lists files of all shards with keys, starting with the prefix.
+// Shards, which don't implement PrefixLister, list all their files.
+func (s *Sharded) ListPrefix(ctx context.Context, prefix string) ([]FileMeta, error) {
+	return s.list(func(store Store) ([]FileMeta, error) { return listPrefix(ctx, store, prefix) })
+}
+
+// list merges files, listed from all shards.
+func (s *Sharded) list(fn func(store Store) ([]FileMeta, error)) ([]FileMeta, error) {
+	var res []FileMeta
+	seen := map[string]bool{}
+
+	for name, store := range s.snapshot() {
+		files, err := fn(store)
+		if err != nil {
+			return nil, fmt.Errorf("list files of shard %q: %w", name, err)
+		}
+
+		for _, file := range files {
+			if !seen[file.Key] {
+				seen[file.Key] = true
+				res = append(res, file)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// lookup returns the shard, which keeps the file, checking its previous
+// shards, if it's absent in the current one.
+func (s *Sharded) lookup(ctx context.Context, key string) (Store, error) {
+	_, store := s.owner(key)
+	if store == nil {
+		return nil, ErrNoShards
+	}
+
+	prev := s.previous(key)
+	if len(prev) == 0 {
+		return store, nil
+	}
+
+	for _, st := range append([]Store{store}, prev...) {
+		_, err := st.Meta(ctx, key)
+		if err == nil {
+			return st, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// move copies the file from src to dst and removes it from src. The key
+// is locked, so the file, put concurrently, is not overwritten by its
+// stale copy.
+func (s *Sharded) move(ctx context.Context, key string, src, dst Store) error {
+	unlock := s.keys.lock(key)
+	defer unlock()
+
+	meta, err := src.Meta(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil // removed concurrently
+	}
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+
+	rd, err := src.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get file: %w", err)
+	}
+
+	if err = dst.Put(ctx, key, meta, rd); err != nil {
+		return fmt.Errorf("put file: %w", err)
+	}
+
+	if err = src.Remove(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("remove file: %w", err)
+	}
+
+	return nil
+}
+
+// owner returns the shard, which the key belongs to.
+func (s *Sharded) owner(key string) (string, Store) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, ok := s.ring.owner(key)
+	if !ok {
+		return "", nil
+	}
+	return name, s.shards[name]
+}
+
+// previous returns the shards, which the key belonged to before adding
+// shards, except the current one.
+func (s *Sharded) previous(key string) []Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	current, _ := s.ring.owner(key)
+	seen := map[string]bool{current: true}
+
+	var res []Store
+	for _, ring := range s.prev {
+		if name, ok := ring.owner(key); ok && !seen[name] {
+			seen[name] = true
+			res = append(res, s.shards[name])
+		}
+	}
+	return res
+}
+
+func (s *Sharded) snapshot() map[string]Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make(map[string]Store, len(s.shards))
+	for name, store := range s.shards {
+		res[name] = store
+	}
+	return res
+}
+
+// hashRing places virtual nodes of shards on the ring of hashes, the key
+// belongs to the first node, clockwise from its hash.
+type hashRing struct {
+	nodes []ringNode // sorted by hash
+}
+
+type ringNode struct {
+	hash  uint64
+	shard string
+}
+
+func newHashRing(shards []string, virtualNodes int) hashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+
+	res := hashRing{nodes: make([]ringNode, 0, len(shards)*virtualNodes)}
+	for _, shard := range shards {
+		for i := 0; i < virtualNodes; i++ {
+			res.nodes = append(res.nodes, ringNode{hash: hashKey(shard + "#" + strconv.Itoa(i)), shard: shard})
+		}
+	}
+
+	sort.Slice(res.nodes, func(i, j int) bool {
+		if res.nodes[i].hash != res.nodes[j].hash {
+			return res.nodes[i].hash < res.nodes[j].hash
+		}
+		return res.nodes[i].shard < res.nodes[j].shard
+	})
+
+	return res
+}
+
+// owner returns the shard of the key.
+func (r hashRing) owner(key string) (string, bool) {
+	if len(r.nodes) == 0 {
+		return "", false
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.nodes), func(i int) bool { return r.nodes[i].hash >= h })
+	if i == len(r.nodes) {
+		i = 0
+	}
+
+	return r.nodes[i].shard, true
+}
+
+// names returns names of shards on the ring.
+func (r hashRing) names() []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, node := range r.nodes {
+		if !seen[node.shard] {
+			seen[node.shard] = true
+			res = append(res, node.shard)
+		}
+	}
+	return res
+}
+
+// hashKey returns the hash of the string. FNV hashes of similar strings,
+// like names of virtual nodes, are close to each other, thus they are
+// mixed to spread nodes evenly over the ring.
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	// finalizer of MurmurHash3
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,203 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharded(t *testing.T) {
+	ctx := context.Background()
+
+	prepare := func(t *testing.T, names ...string) (*Sharded, map[string]*Memory) {
+		shards := map[string]*Memory{}
+		stores := map[string]Store{}
+		for _, name := range names {
+			shards[name] = NewMemory(0, NopLogger())
+			stores[name] = shards[name]
+		}
+		return NewSharded(stores, NopLogger()), shards
+	}
+
+	putKeys := func(t *testing.T, svc *Sharded, n int) {
+		for i := 0; i < n; i++ {
+			require.NoError(t, svc.Put(ctx, fmt.Sprintf("key-%d", i), FileMeta{}, stringReader(fmt.Sprintf("data-%d", i))))
+		}
+	}
+
+	shardKeys := func(t *testing.T, m *Memory) map[string]bool {
+		keys, err := m.Keys(ctx)
+		require.NoError(t, err)
+		res := map[string]bool{}
+		for _, key := range keys {
+			res[key] = true
+		}
+		return res
+	}
+
+	t.Run("distribute", func(t *testing.T) {
+		svc, shards := prepare(t, "a", "b", "c")
+		putKeys(t, svc, 300)
+
+		for name, shard := range shards {
+			n := len(shardKeys(t, shard))
+			assert.True(t, n > 50 && n < 150, "shard %s has %d keys", name, n)
+		}
+
+		keys, err := svc.Keys(ctx)
+		require.NoError(t, err)
+		assert.Len(t, keys, 300)
+
+		stat, err := svc.Stat(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 300, stat.Keys)
+
+		rd, err := svc.Get(ctx, "key-42")
+		require.NoError(t, err)
+		assertReader(t, rd, "data-42")
+	})
+
+	t.Run("add shard and rebalance", func(t *testing.T) {
+		svc, shards := prepare(t, "a", "b")
+		putKeys(t, svc, 200)
+		before := map[string]map[string]bool{"a": shardKeys(t, shards["a"]), "b": shardKeys(t, shards["b"])}
+
+		shards["c"] = NewMemory(0, NopLogger())
+		require.NoError(t, svc.AddShard("c", shards["c"]))
+		assert.Error(t, svc.AddShard("c", shards["c"]))
+
+		owned := map[string]bool{}
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if name, _ := svc.owner(key); name == "c" {
+				owned[key] = true
+			}
+		}
+		require.NotEmpty(t, owned)
+
+		// files are still accessible before rebalancing
+		for i := 0; i < 200; i++ {
+			rd, err := svc.Get(ctx, fmt.Sprintf("key-%d", i))
+			require.NoError(t, err)
+			assertReader(t, rd, fmt.Sprintf("data-%d", i))
+		}
+
+		// put of the moved key removes the stale copy
+		var moved string
+		for key := range owned {
+			moved = key
+			break
+		}
+		require.NoError(t, svc.Put(ctx, moved, FileMeta{}, stringReader("new data")))
+		assert.False(t, shardKeys(t, shards["a"])[moved] || shardKeys(t, shards["b"])[moved])
+
+		n, err := svc.Rebalance(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, len(owned)-1, n)
+
+		assert.Equal(t, owned, shardKeys(t, shards["c"]))
+		for _, name := range []string{"a", "b"} {
+			for key := range shardKeys(t, shards[name]) {
+				assert.True(t, before[name][key], "key %s moved to shard %s", key, name)
+			}
+		}
+
+		rd, err := svc.Get(ctx, moved)
+		require.NoError(t, err)
+		assertReader(t, rd, "new data")
+
+		keys, err := svc.Keys(ctx)
+		require.NoError(t, err)
+		assert.Len(t, keys, 200)
+
+		n, err = svc.Rebalance(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("put during rebalance", func(t *testing.T) {
+		src := &blockingGetStore{Memory: NewMemory(0, NopLogger()), getting: make(chan struct{}), release: make(chan struct{})}
+		svc := NewSharded(map[string]Store{"a": src}, NopLogger())
+		putKeys(t, svc, 20)
+		require.NoError(t, svc.AddShard("b", NewMemory(0, NopLogger())))
+
+		var key string
+		for i := 0; i < 20 && key == ""; i++ {
+			if name, _ := svc.owner(fmt.Sprintf("key-%d", i)); name == "b" {
+				key = fmt.Sprintf("key-%d", i)
+			}
+		}
+		require.NotEmpty(t, key)
+		src.blockKey = key
+
+		rebalanced := make(chan error, 1)
+		go func() {
+			_, err := svc.Rebalance(ctx)
+			rebalanced <- err
+		}()
+		<-src.getting
+
+		put := make(chan error, 1)
+		go func() { put <- svc.Put(ctx, key, FileMeta{}, stringReader("new data")) }()
+
+		// put waits for the move to finish
+		require.Eventually(t, func() bool {
+			svc.keys.mu.Lock()
+			defer svc.keys.mu.Unlock()
+			return svc.keys.locks[key] != nil && svc.keys.locks[key].refs == 2
+		}, time.Second, time.Millisecond)
+		close(src.release)
+
+		require.NoError(t, <-rebalanced)
+		require.NoError(t, <-put)
+
+		rd, err := svc.Get(ctx, key)
+		require.NoError(t, err)
+		assertReader(t, rd, "new data")
+	})
+
+	t.Run("list prefix", func(t *testing.T) {
+		svc, _ := prepare(t, "a", "b", "c")
+		putKeys(t, svc, 20)
+		require.NoError(t, svc.Put(ctx, "dir/key", FileMeta{}, stringReader("1234")))
+
+		files, err := svc.ListPrefix(ctx, "key-1")
+		require.NoError(t, err)
+		keys := make([]string, 0, len(files))
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+		sort.Strings(keys)
+		assert.Equal(t, []string{"key-1", "key-10", "key-11", "key-12", "key-13", "key-14",
+			"key-15", "key-16", "key-17", "key-18", "key-19"}, keys)
+	})
+
+	t.Run("no shards", func(t *testing.T) {
+		svc, _ := prepare(t)
+		_, err := svc.Meta(ctx, "key")
+		assert.ErrorIs(t, err, ErrNoShards)
+		assert.ErrorIs(t, svc.Put(ctx, "key", FileMeta{}, stringReader("1234")), ErrNoShards)
+	})
+}
+
+// blockingGetStore blocks Get of the key until released.
+type blockingGetStore struct {
+	*Memory
+	blockKey string
+	getting  chan struct{}
+	release  chan struct{}
+}
+
+func (s *blockingGetStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if key == s.blockKey {
+		close(s.getting)
+		<-s.release
+	}
+	return s.Memory.Get(ctx, key)
+}
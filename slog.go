@@ -0,0 +1,22 @@
+//go:build go1.21
+// +build go1.21
+
+package fcache
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger adapts slog.Logger to StructuredLogger.
+func SlogLogger(log *slog.Logger) StructuredLogger { return slogLogger{log: log} }
+
+type slogLogger struct{ log *slog.Logger }
+
+func (s slogLogger) Log(ctx context.Context, level Level, msg string, attrs ...Attr) {
+	res := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		res = append(res, slog.Any(attr.Key, attr.Value))
+	}
+	s.log.LogAttrs(ctx, slog.Level(level), msg, res...)
+}
@@ -0,0 +1,38 @@
+//go:build go1.21
+// +build go1.21
+
+package fcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := SlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	log.Log(context.Background(), LevelWarn, "failed to reload file",
+		StringAttr(LogKey, "key"),
+		DurationAttr(LogDuration, time.Second),
+		ErrorAttr(errors.New("origin failure")),
+	)
+	log.Log(context.Background(), LevelDebug, "reloaded file", Int64Attr(LogCount, 2))
+
+	assert.Equal(t, `level=WARN msg="failed to reload file" key=key duration=1s error="origin failure"`+"\n"+
+		`level=DEBUG msg="reloaded file" count=2`+"\n", buf.String())
+}
@@ -0,0 +1,126 @@
+package fcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// spool keeps the content of the loaded file to be read by several
+// independent readers.
+type spool interface {
+	io.Writer
+	// seal finishes writing of the content.
+	seal() error
+	// acquire reserves n references to the content.
+	acquire(n int)
+	// open opens a new independent reader of the content, using previously
+	// reserved reference, which is released, when the reader is closed.
+	open() (io.ReadCloser, error)
+	// release releases the reference without opening the reader.
+	release()
+}
+
+// spoolFile is a temporary file, keeping the loaded content.
+// The file is removed once all references are released.
+type spoolFile struct {
+	path string
+	wr   *os.File
+
+	mu   sync.Mutex
+	refs int
+}
+
+// newSpoolFile creates a temporary file in the given directory with a single
+// reference, held by the creator.
+func newSpoolFile(dir string) (*spoolFile, error) {
+	f, err := os.CreateTemp(dir, "fcache_*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+
+	return &spoolFile{path: f.Name(), wr: f, refs: 1}, nil
+}
+
+func (s *spoolFile) Write(p []byte) (int, error) { return s.wr.Write(p) }
+
+func (s *spoolFile) seal() error {
+	if err := s.wr.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	return nil
+}
+
+func (s *spoolFile) acquire(n int) {
+	s.mu.Lock()
+	s.refs += n
+	s.mu.Unlock()
+}
+
+// release releases the reference to the file and removes it, if it was the
+// last one.
+func (s *spoolFile) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs--
+	if s.refs == 0 {
+		_ = s.wr.Close() // in case if the spool hasn't been sealed
+		_ = os.Remove(s.path)
+	}
+}
+
+func (s *spoolFile) open() (io.ReadCloser, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		s.release()
+		return nil, fmt.Errorf("open spool file: %w", err)
+	}
+
+	rd := &spoolReader{File: f, spool: s}
+	// the reader might never be closed by the caller, so the file is
+	// removed, when the reader is garbage collected
+	runtime.SetFinalizer(rd, func(rd *spoolReader) { _ = rd.Close() })
+
+	return rd, nil
+}
+
+// spoolReader reads the spool file and releases the reference to it on close.
+type spoolReader struct {
+	*os.File
+	spool *spoolFile
+	once  sync.Once
+}
+
+// Close closes the file and releases the reference to it.
+func (r *spoolReader) Close() (err error) {
+	r.once.Do(func() {
+		runtime.SetFinalizer(r, nil)
+		defer r.spool.release()
+		if err = r.File.Close(); err != nil {
+			err = fmt.Errorf("close file: %w", err)
+		}
+	})
+	return err
+}
+
+// spoolMemory keeps the loaded content in memory.
+type spoolMemory struct{ buf bytes.Buffer }
+
+func (s *spoolMemory) Write(p []byte) (int, error) { return s.buf.Write(p) }
+func (s *spoolMemory) seal() error                 { return nil }
+func (s *spoolMemory) acquire(int)                 {}
+func (s *spoolMemory) release()                    {}
+
+func (s *spoolMemory) open() (io.ReadCloser, error) {
+	// the buffer is never modified after sealing, so it's safe to share it
+	return memoryReader{Reader: bytes.NewReader(s.buf.Bytes())}, nil
+}
+
+// memoryReader is a seekable no-op closer over the in-memory content.
+type memoryReader struct{ *bytes.Reader }
+
+func (memoryReader) Close() error { return nil }
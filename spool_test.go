@@ -0,0 +1,113 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_GetFile_Spool(t *testing.T) {
+	loader := func(size int64) Loader {
+		return func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			return io.NopCloser(strings.NewReader("some file data")), FileMeta{Size: size}, nil
+		}
+	}
+
+	t.Run("spill dir", func(t *testing.T) {
+		dir := t.TempDir()
+		svc := NewLoadingCache(NewMemory(0, NopLogger()),
+			WithLogger(NopLogger()),
+			WithSpillDir(dir),
+			WithMemoryBufferThreshold(10),
+		)
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(14)})
+		require.NoError(t, err)
+		assertDirLen(t, dir, 1)
+
+		bts, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		assert.Equal(t, "some file data", string(bts))
+		require.NoError(t, rd.Close())
+		assertDirLen(t, dir, 0)
+	})
+
+	t.Run("memory buffer", func(t *testing.T) {
+		dir := t.TempDir()
+		svc := NewLoadingCache(NewMemory(0, NopLogger()),
+			WithLogger(NopLogger()),
+			WithSpillDir(dir),
+			WithMemoryBufferThreshold(14),
+		)
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(14)})
+		require.NoError(t, err)
+		assertDirLen(t, dir, 0)
+		assert.IsType(t, memoryReader{}, rd)
+
+		bts, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		assert.Equal(t, "some file data", string(bts))
+		require.NoError(t, rd.Close())
+	})
+
+	t.Run("unknown size is spilled", func(t *testing.T) {
+		dir := t.TempDir()
+		svc := NewLoadingCache(NewMemory(0, NopLogger()),
+			WithLogger(NopLogger()),
+			WithSpillDir(dir),
+			WithMemoryBufferThreshold(100),
+		)
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(0)})
+		require.NoError(t, err)
+		assertDirLen(t, dir, 1)
+		require.NoError(t, rd.Close())
+	})
+
+	t.Run("put failure", func(t *testing.T) {
+		dir := t.TempDir()
+		svc := NewLoadingCache(&StoreMock{
+			MetaFunc: func(ctx context.Context, key string) (FileMeta, error) { return FileMeta{}, ErrNotFound },
+			PutFunc: func(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+				_, err := io.ReadAll(rd)
+				require.NoError(t, err)
+				return errors.New("store failure")
+			},
+		}, WithLogger(NopLogger()), WithSpillDir(dir))
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(14)})
+		assert.EqualError(t, err, "put file into storage: store failure")
+		assert.Nil(t, rd)
+		assertDirLen(t, dir, 0)
+	})
+
+	t.Run("reader is never closed", func(t *testing.T) {
+		dir := t.TempDir()
+		svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()), WithSpillDir(dir))
+
+		_, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: loader(14)})
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			runtime.GC()
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			return len(entries) == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func assertDirLen(t *testing.T, dir string, expected int) {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, expected)
+}
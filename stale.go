@@ -0,0 +1,186 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	defaultRevalidateWorkers = 4
+	metaStaleUntilKey        = "_stale_until"
+)
+
+// errShutdown is returned to the callers, waiting for the background reload,
+// which hasn't been started due to the cache's shutdown.
+var errShutdown = errors.New("cache is shut down")
+
+// entryState describes the state of the cached file.
+type entryState int
+
+const (
+	stateMissing entryState = iota // file is absent or expired
+	stateFresh                     // file is within its TTL
+	stateStale                     // file is expired, but can be served
+)
+
+// lookup returns the meta of the cached file along with its state.
+// Expired files, which are within the request's stale window, are served,
+// while being reloaded in background.
+func (l *LoadingCache) lookup(ctx context.Context, req GetRequest) (FileMeta, entryState, error) {
+	var meta FileMeta
+	err := l.traced(ctx, "fcache.Store.Meta", req.Key, func(ctx context.Context) (err error) {
+		meta, err = l.Store.Meta(ctx, req.Key)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return FileMeta{}, stateMissing, nil
+	}
+	if err != nil {
+		return FileMeta{}, stateMissing, err
+	}
+
+	invalidateAt, ok := meta.ExpiresAt()
+	if !ok {
+		return meta, stateFresh, nil
+	}
+
+	now := l.now()
+
+	switch {
+	case now.Before(invalidateAt):
+		l.refreshAhead(req, invalidateAt.Sub(now))
+		return meta, stateFresh, nil
+	case req.Loader != nil && now.Before(invalidateAt.Add(req.MaxStale)):
+		l.revalidate(req)
+		return meta, stateStale, nil
+	default:
+		return FileMeta{}, stateMissing, nil
+	}
+}
+
+// revalidate reloads the file in background, unless it is being loaded
+// already. Reloads are bounded by the worker pool, if all workers are busy,
+// the reload is skipped and will be triggered by one of the next hits.
+// Loader is called with the background context.
+func (l *LoadingCache) revalidate(req GetRequest) {
+	// caches, made without the constructor, reload files without limits
+	if l.revalidateSem != nil {
+		select {
+		case l.revalidateSem <- struct{}{}:
+		default:
+			return // all workers are busy
+		}
+	}
+	release := func() {
+		if l.revalidateSem != nil {
+			<-l.revalidateSem
+		}
+	}
+
+	c, leader := l.inflight.join(req.Key)
+	if !leader {
+		l.inflight.leave(c)
+		release()
+		return
+	}
+
+	started := l.background(func(ctx context.Context) {
+		defer release()
+		l.reload(ctx, req, c, "stale")
+	})
+	if !started {
+		release()
+		l.inflight.finish(req.Key, c, FileMeta{}, nil, errShutdown)
+	}
+}
+
+// background runs fn in background with the context, which is canceled on
+// Shutdown. It reports false, if the cache is shut down and fn hasn't been
+// started.
+func (l *LoadingCache) background(fn func(ctx context.Context)) bool {
+	l.bgMu.Lock()
+	defer l.bgMu.Unlock()
+
+	if l.shutdown {
+		return false
+	}
+
+	if l.bgCtx == nil {
+		l.bgCtx, l.bgCancel = context.WithCancel(context.Background())
+	}
+
+	ctx := l.bgCtx
+	l.bg.Add(1)
+	go func() {
+		defer l.bg.Done()
+		fn(ctx)
+	}()
+
+	return true
+}
+
+// reload loads the file with the background context and puts it into
+// the store, finishing the in-flight call.
+func (l *LoadingCache) reload(ctx context.Context, req GetRequest, c *call, kind string) {
+	start := time.Now()
+	meta, err := l.load(ctx, req)
+	l.inflight.finish(req.Key, c, meta, nil, err)
+
+	attrs := []Attr{
+		StringAttr(LogKey, req.Key),
+		StringAttr(LogOp, string(OpReload)),
+		StringAttr("kind", kind),
+		DurationAttr(LogDuration, time.Since(start)),
+	}
+	if err != nil {
+		l.log(ctx, LevelWarn, "failed to reload file", append(attrs, ErrorAttr(err))...)
+		return
+	}
+
+	l.log(ctx, LevelDebug, "reloaded file", attrs...)
+}
+
+// withStaleWindow sets the end of the period after the file's expiration,
+// during which the file may be served, so that Invalidate keeps it till then.
+func withStaleWindow(meta FileMeta, maxStale time.Duration) FileMeta {
+	expiresAt, ok := meta.ExpiresAt()
+	if !ok || maxStale <= 0 {
+		return meta
+	}
+
+	meta = copyMeta(meta)
+	meta.Meta[metaStaleUntilKey] = expiresAt.Add(maxStale).Format(metaTimeFormat)
+	return meta
+}
+
+// staleUntil returns the end of the period, during which the expired file
+// may be served, if it has been set.
+func (m FileMeta) staleUntil() (time.Time, bool) {
+	v, ok := m.Meta[metaStaleUntilKey]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	tm, err := time.Parse(metaTimeFormat, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return tm, true
+}
+
+// ExpiresAt returns the time, when the file expires, if it has been set.
+func (m FileMeta) ExpiresAt() (time.Time, bool) {
+	v, ok := m.Meta[metaInvalidateAtKey]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	tm, err := time.Parse(metaTimeFormat, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return tm, true
+}
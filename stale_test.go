@@ -0,0 +1,234 @@
+package fcache
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_GetFile_Expired(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	prepare := func(t *testing.T) (*LoadingCache, *Memory) {
+		store := NewMemory(0, NopLogger())
+		svc := NewLoadingCache(store, WithLogger(NopLogger()))
+		svc.now = func() time.Time { return now }
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			TTL:    time.Minute,
+			Loader: stringLoader("old data"),
+		})
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+
+		return svc, store
+	}
+
+	t.Run("fresh", func(t *testing.T) {
+		svc, _ := prepare(t)
+		svc.now = func() time.Time { return now.Add(30 * time.Second) }
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", Loader: stringLoader("new data")})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, svc.CacheStats)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		svc, store := prepare(t)
+		svc.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+		rd, meta, err := svc.GetFile(context.Background(), GetRequest{
+			Key:      "key",
+			TTL:      time.Minute,
+			MaxStale: 30 * time.Second,
+			Loader:   stringLoader("new data"),
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "new data")
+		assert.Equal(t, now.Add(3*time.Minute).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+		assert.Equal(t, CacheStats{Misses: 2}, svc.CacheStats)
+		assertMemoryContent(t, store, "key", "new data")
+	})
+
+	t.Run("expiration set by loader", func(t *testing.T) {
+		svc, store := prepare(t)
+		svc.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+		rd, meta, err := svc.GetFile(context.Background(), GetRequest{
+			Key: "key",
+			TTL: time.Minute,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return stringReader("new data"), FileMeta{}.WithExpiration(now.Add(time.Hour)), nil
+			},
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "new data")
+
+		expiresAt, ok := meta.ExpiresAt()
+		require.True(t, ok)
+		assert.Equal(t, now.Add(time.Hour), expiresAt.UTC())
+		assertMemoryContent(t, store, "key", "new data")
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		svc, store := prepare(t)
+		svc.now = func() time.Time { return now.Add(80 * time.Second) }
+
+		rd, meta, err := svc.GetFile(context.Background(), GetRequest{
+			Key:      "key",
+			TTL:      time.Minute,
+			MaxStale: 30 * time.Second,
+			Loader:   stringLoader("new data"),
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+		assert.Equal(t, now.Add(time.Minute).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+
+		svc.bg.Wait()
+		assertMemoryContent(t, store, "key", "new data")
+		meta, err = store.Meta(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(140*time.Second).Format(metaTimeFormat), meta.Meta[metaInvalidateAtKey])
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, svc.CacheStats)
+	})
+
+	t.Run("workers are busy", func(t *testing.T) {
+		svc, _ := prepare(t)
+		svc.revalidateSem = make(chan struct{}, 1)
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "other", TTL: time.Minute, Loader: stringLoader("old data")})
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+		svc.now = func() time.Time { return now.Add(80 * time.Second) }
+
+		release := make(chan struct{})
+		var loads int64
+		loader := func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			atomic.AddInt64(&loads, 1)
+			<-release
+			return stringReader("new data"), FileMeta{}, nil
+		}
+
+		for _, key := range []string{"key", "other"} {
+			rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: key, MaxStale: time.Minute, Loader: loader})
+			require.NoError(t, err)
+			assertReader(t, rd, "old data")
+		}
+
+		close(release)
+		svc.bg.Wait()
+		assert.Equal(t, int64(1), atomic.LoadInt64(&loads))
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		svc, store := prepare(t)
+		svc.now = func() time.Time { return now.Add(80 * time.Second) }
+
+		started := make(chan struct{})
+		loader := func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, FileMeta{}, ctx.Err()
+		}
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{Key: "key", MaxStale: time.Minute, Loader: loader})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+
+		<-started
+		require.NoError(t, svc.Shutdown(context.Background()))
+		assertMemoryContent(t, store, "key", "old data")
+
+		// no reloads after shutdown
+		rd, _, err = svc.GetFile(context.Background(), GetRequest{Key: "key", MaxStale: time.Minute, Loader: loader})
+		require.NoError(t, err)
+		assertReader(t, rd, "old data")
+		svc.bg.Wait()
+	})
+}
+
+func TestLoadingCache_GetURL_Stale(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	store := newTestFS(t, now)
+	svc := NewLoadingCache(store, WithLogger(NopLogger()))
+	svc.now = func() time.Time { return now }
+
+	_, _, err := svc.GetURL(context.Background(), GetRequest{
+		Key:    "key",
+		TTL:    time.Minute,
+		Loader: stringLoader("old data"),
+	}, GetURLParams{})
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return now.Add(80 * time.Second) }
+	u, _, err := svc.GetURL(context.Background(), GetRequest{
+		Key:      "key",
+		TTL:      time.Minute,
+		MaxStale: time.Minute,
+		Loader:   stringLoader("new data"),
+	}, GetURLParams{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, u)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, svc.CacheStats)
+
+	svc.bg.Wait()
+	rd, err := store.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assertReader(t, rd, "new data")
+}
+
+func TestLoadingCache_Run_StaleWindow(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	store := NewMemory(0, NopLogger())
+	metrics := &metricsRecorder{counts: map[string]int{}}
+	svc := NewLoadingCache(store, WithLogger(NopLogger()), WithMetrics(metrics),
+		WithInvalidationPeriod(time.Millisecond))
+	svc.now = func() time.Time { return now }
+
+	req := GetRequest{Key: "key", TTL: time.Minute, MaxStale: time.Minute, Loader: stringLoader("old data")}
+	rd, meta, err := svc.GetFile(context.Background(), req)
+	require.NoError(t, err)
+	assertReader(t, rd, "old data")
+	assert.Equal(t, now.Add(2*time.Minute).Format(metaTimeFormat), meta.Meta[metaStaleUntilKey])
+
+	// within the stale window
+	svc.now = func() time.Time { return now.Add(90 * time.Second) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.ErrorIs(t, svc.Run(ctx), context.Canceled)
+	}()
+
+	require.Eventually(t, func() bool {
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+		return metrics.counts["invalidated"] >= 3
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assertMemoryContent(t, store, "key", "old data")
+
+	// after the stale window
+	svc.now = func() time.Time { return now.Add(3 * time.Minute) }
+	invalidated, err := svc.Invalidate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), invalidated)
+}
+
+func assertReader(t *testing.T, rd io.ReadCloser, expected string) {
+	bts, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.NoError(t, rd.Close())
+	assert.Equal(t, expected, string(bts))
+}
@@ -10,6 +10,9 @@ import (
 // ErrNotFound represents a not found error.
 var ErrNotFound = errors.New("not found")
 
+// ErrNotSupported is returned when the store doesn't support the operation.
+var ErrNotSupported = errors.New("not supported")
+
 //go:generate rm -f store_mock.go
 //go:generate moq -out store_mock.go -fmt goimports . Store
 
@@ -32,6 +35,20 @@ type Store interface {
 	List(ctx context.Context) ([]FileMeta, error)
 }
 
+// PrefixLister is implemented by stores, which are able to list only files
+// with keys, starting with the given prefix, without listing all files.
+type PrefixLister interface {
+	ListPrefix(ctx context.Context, prefix string) ([]FileMeta, error)
+}
+
+// RangeGetter is implemented by stores, which are able to read a part of
+// the file without reading it from the beginning.
+type RangeGetter interface {
+	// GetRange returns the reader of length bytes of the file, starting at
+	// the offset. Negative length means "till the end of the file".
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
 // StoreStats represents stats of the backend store.
 type StoreStats struct {
 	Keys int
@@ -44,7 +61,8 @@ type FileMeta struct {
 	Mime string
 
 	// Meta maintains additional data about the file.
-	// Note: some metadata keys with leading underscore are reserved by fcache.
+	// Note: some metadata keys with leading underscore are reserved by fcache,
+	// e.g. for TTL and tags.
 	Meta map[string]string
 
 	// Size might not be provided when loading file, though it might be useful
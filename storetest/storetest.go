@@ -0,0 +1,414 @@
+// Package storetest provides the conformance test suite for fcache.Store
+// implementations.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory makes new empty instance of the store under test.
+// The store must be cleaned up by the factory, e.g. with t.Cleanup.
+type Factory func(t *testing.T) fcache.Store
+
+// Run checks that the store, made by the factory, follows the contract of
+// fcache.Store, as it's expected by fcache.LoadingCache. Each check runs
+// as a subtest with its own store.
+//
+// The suite expects the store to:
+//   - return fcache.ErrNotFound from Meta and GetURL for absent files,
+//     an error from UpdateMeta, and an error either from Get or from
+//     reading its result;
+//   - keep the content, name, mime and meta of the file, including the keys,
+//     reserved by fcache, which start with underscore. Meta keys are
+//     lowercase, stores may change their case;
+//   - set the key, the size and the creation time of the file on put;
+//   - replace the file and its meta on put to the same key;
+//   - update only the name and meta of the file on UpdateMeta;
+//   - report the same files in Keys, List and Stat;
+//   - handle concurrent puts;
+//   - return either fcache.ErrNotSupported or non-empty URL from GetURL,
+//     HTTP URLs must serve the file's content;
+//   - return the requested part of the file from GetRange and only the
+//     files with the prefix from ListPrefix, if implemented.
+//
+// Optional behavior is checked only if enabled by options.
+func Run(t *testing.T, factory Factory, opts ...Option) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	tbl := []struct {
+		name string
+		fn   func(t *testing.T, s fcache.Store)
+		skip bool
+	}{
+		{name: "not found", fn: func(t *testing.T, s fcache.Store) { testNotFound(t, s, o.removeNotFound) }},
+		{name: "put and get", fn: testPutGet},
+		{name: "put without size", fn: testPutWithoutSize, skip: !o.unknownSize},
+		{name: "overwrite", fn: testOverwrite},
+		{name: "update meta", fn: func(t *testing.T, s fcache.Store) { testUpdateMeta(t, s, o.mimeUpdate) }},
+		{name: "remove", fn: func(t *testing.T, s fcache.Store) { testRemove(t, s, o.removeNotFound) }},
+		{name: "keys, list and stat", fn: testKeysListStat},
+		{name: "concurrent put", fn: testConcurrentPut},
+		{name: "get url", fn: testGetURL},
+		{name: "get range", fn: testGetRange},
+		{name: "list prefix", fn: testListPrefix},
+	}
+
+	for _, tt := range tbl {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.skip {
+				t.Skip("not enabled for the store")
+			}
+			tt.fn(t, factory(t))
+		})
+	}
+}
+
+// Option enables the check of optional behavior of the store.
+type Option func(o *options)
+
+type options struct {
+	unknownSize    bool
+	mimeUpdate     bool
+	removeNotFound bool
+}
+
+// WithUnknownSize checks that the store sets the size of the file on put,
+// if it is not provided.
+func WithUnknownSize() Option {
+	return func(o *options) { o.unknownSize = true }
+}
+
+// WithRemoveNotFound checks that the store returns fcache.ErrNotFound from
+// Remove for absent files.
+func WithRemoveNotFound() Option {
+	return func(o *options) { o.removeNotFound = true }
+}
+
+// WithMimeUpdate checks that the store updates the mime of the file on
+// UpdateMeta.
+func WithMimeUpdate() Option {
+	return func(o *options) { o.mimeUpdate = true }
+}
+
+func testNotFound(t *testing.T, s fcache.Store, removeNotFound bool) {
+	ctx := context.Background()
+
+	_, err := s.Meta(ctx, "absent")
+	assert.ErrorIs(t, err, fcache.ErrNotFound, "meta")
+
+	err = s.UpdateMeta(ctx, "absent", fcache.FileMeta{Name: "a.txt"})
+	assert.Error(t, err, "update meta")
+
+	_, err = s.GetURL(ctx, "absent", fcache.GetURLParams{})
+	assert.ErrorIs(t, err, fcache.ErrNotFound, "get url")
+
+	if removeNotFound {
+		err = s.Remove(ctx, "absent")
+		assert.ErrorIs(t, err, fcache.ErrNotFound, "remove")
+	}
+
+	rd, err := s.Get(ctx, "absent")
+	if err == nil {
+		_, err = io.ReadAll(rd)
+		_ = rd.Close()
+	}
+	assert.Error(t, err, "get")
+}
+
+func testPutGet(t *testing.T, s fcache.Store) {
+	ctx := context.Background()
+
+	for _, key := range []string{"key", "dir/sub/file.txt"} {
+		put(t, s, key, "some file data", fcache.FileMeta{
+			Name: "a.txt",
+			Mime: "text/plain",
+			Size: 14,
+			Meta: map[string]string{
+				"_invalidate_at": "2022-07-05T06:51:21Z",
+				"_tags":          "tag-1,tag-2",
+				"custom":         "value",
+			},
+		})
+
+		meta, err := s.Meta(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, key, meta.Key)
+		assert.Equal(t, "a.txt", meta.Name)
+		assert.Equal(t, "text/plain", meta.Mime)
+		assert.Equal(t, int64(14), meta.Size)
+		assert.False(t, meta.CreatedAt.IsZero(), "creation time is not set")
+		assertMeta(t, map[string]string{
+			"_invalidate_at": "2022-07-05T06:51:21Z",
+			"_tags":          "tag-1,tag-2",
+			"custom":         "value",
+		}, meta)
+
+		assert.Equal(t, "some file data", get(t, s, key))
+	}
+}
+
+func testPutWithoutSize(t *testing.T, s fcache.Store) {
+	put(t, s, "key", "some file data", fcache.FileMeta{})
+
+	meta, err := s.Meta(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), meta.Size)
+	assert.Equal(t, "some file data", get(t, s, "key"))
+}
+
+func testOverwrite(t *testing.T, s fcache.Store) {
+	put(t, s, "key", "old data", fcache.FileMeta{Name: "old.txt", Size: 8, Meta: map[string]string{"old": "value"}})
+	put(t, s, "key", "new file data", fcache.FileMeta{Name: "new.txt", Size: 13, Meta: map[string]string{"new": "value"}})
+
+	meta, err := s.Meta(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "new.txt", meta.Name)
+	assert.Equal(t, int64(13), meta.Size)
+	assertMeta(t, map[string]string{"new": "value"}, meta)
+	assert.Equal(t, "new file data", get(t, s, "key"))
+
+	keys, err := s.Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+}
+
+func testUpdateMeta(t *testing.T, s fcache.Store, mimeUpdate bool) {
+	ctx := context.Background()
+
+	put(t, s, "key", "some file data", fcache.FileMeta{
+		Name: "a.txt",
+		Mime: "text/plain",
+		Size: 14,
+		Meta: map[string]string{"_invalidate_at": "2022-07-05T06:51:21Z"},
+	})
+
+	err := s.UpdateMeta(ctx, "key", fcache.FileMeta{
+		Name: "b.txt",
+		Mime: "text/csv",
+		Meta: map[string]string{"_invalidate_at": "2022-07-05T07:51:21Z", "custom": "value"},
+	})
+	require.NoError(t, err)
+
+	meta, err := s.Meta(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "key", meta.Key)
+	assert.Equal(t, "b.txt", meta.Name)
+	if mimeUpdate {
+		assert.Equal(t, "text/csv", meta.Mime)
+	}
+	assert.Equal(t, int64(14), meta.Size)
+	assertMeta(t, map[string]string{"_invalidate_at": "2022-07-05T07:51:21Z", "custom": "value"}, meta)
+	assert.Equal(t, "some file data", get(t, s, "key"))
+}
+
+func testRemove(t *testing.T, s fcache.Store, removeNotFound bool) {
+	ctx := context.Background()
+
+	put(t, s, "key-1", "1234", fcache.FileMeta{Size: 4})
+	put(t, s, "key-2", "5678", fcache.FileMeta{Size: 4})
+
+	require.NoError(t, s.Remove(ctx, "key-1"))
+
+	_, err := s.Meta(ctx, "key-1")
+	assert.ErrorIs(t, err, fcache.ErrNotFound)
+	if removeNotFound {
+		assert.ErrorIs(t, s.Remove(ctx, "key-1"), fcache.ErrNotFound)
+	}
+
+	keys, err := s.Keys(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key-2"}, keys)
+	assert.Equal(t, "5678", get(t, s, "key-2"))
+}
+
+func testKeysListStat(t *testing.T, s fcache.Store) {
+	ctx := context.Background()
+
+	stat, err := s.Stat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, fcache.StoreStats{}, stat)
+
+	files := map[string]string{"key-1": "1", "key-2": "22", "dir/key-3": "333"}
+	for key, content := range files {
+		put(t, s, key, content, fcache.FileMeta{Name: key + ".txt", Size: int64(len(content))})
+	}
+
+	keys, err := s.Keys(ctx)
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"dir/key-3", "key-1", "key-2"}, keys)
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, len(files))
+	for _, meta := range list {
+		require.Contains(t, files, meta.Key)
+		assert.Equal(t, int64(len(files[meta.Key])), meta.Size, meta.Key)
+		assert.Equal(t, meta.Key+".txt", meta.Name, meta.Key)
+		assert.False(t, meta.CreatedAt.IsZero(), "creation time of %s is not set", meta.Key)
+	}
+
+	stat, err = s.Stat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, fcache.StoreStats{Keys: 3, Size: 6}, stat)
+}
+
+func testConcurrentPut(t *testing.T, s fcache.Store) {
+	ctx := context.Background()
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2*n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			content := fmt.Sprintf("content of key-%d", i)
+			errs[i] = s.Put(ctx, fmt.Sprintf("key-%d", i), fcache.FileMeta{Size: int64(len(content))},
+				io.NopCloser(strings.NewReader(content)))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			content := strings.Repeat("x", i+1)
+			errs[n+i] = s.Put(ctx, "shared", fcache.FileMeta{Size: int64(len(content))},
+				io.NopCloser(strings.NewReader(content)))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < n; i++ {
+		assert.Equal(t, fmt.Sprintf("content of key-%d", i), get(t, s, fmt.Sprintf("key-%d", i)))
+	}
+
+	// the last put wins, but meta and content must belong to the same put
+	meta, err := s.Meta(ctx, "shared")
+	require.NoError(t, err)
+	content := get(t, s, "shared")
+	assert.Equal(t, strings.Repeat("x", len(content)), content)
+	assert.Equal(t, int64(len(content)), meta.Size)
+
+	stat, err := s.Stat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, n+1, stat.Keys)
+}
+
+func testGetURL(t *testing.T, s fcache.Store) {
+	put(t, s, "key", "some file data", fcache.FileMeta{Name: "a.txt", Size: 14})
+
+	u, err := s.GetURL(context.Background(), "key", fcache.GetURLParams{Filename: "b.txt", Expires: time.Hour})
+	if errors.Is(err, fcache.ErrNotSupported) {
+		return
+	}
+	require.NoError(t, err)
+	require.NotEmpty(t, u)
+
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return
+	}
+
+	resp, err := http.Get(u) //nolint:gosec // url is made by the store under test
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "some file data", string(b))
+}
+
+func testGetRange(t *testing.T, s fcache.Store) {
+	rg, ok := s.(fcache.RangeGetter)
+	if !ok {
+		t.Skip("store doesn't implement fcache.RangeGetter")
+	}
+
+	put(t, s, "key", "0123456789", fcache.FileMeta{Size: 10})
+
+	tbl := []struct {
+		offset, length int64
+		expected       string
+	}{
+		{offset: 0, length: -1, expected: "0123456789"},
+		{offset: 0, length: 4, expected: "0123"},
+		{offset: 3, length: 4, expected: "3456"},
+		{offset: 7, length: -1, expected: "789"},
+		{offset: 7, length: 10, expected: "789"},
+		{offset: 5, length: 0, expected: ""},
+	}
+
+	for _, tt := range tbl {
+		rd, err := rg.GetRange(context.Background(), "key", tt.offset, tt.length)
+		require.NoError(t, err)
+		b, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+		assert.Equal(t, tt.expected, string(b), "offset %d, length %d", tt.offset, tt.length)
+	}
+}
+
+func testListPrefix(t *testing.T, s fcache.Store) {
+	pl, ok := s.(fcache.PrefixLister)
+	if !ok {
+		t.Skip("store doesn't implement fcache.PrefixLister")
+	}
+
+	for _, key := range []string{"dir/key-1", "dir/key-2", "other/key-3"} {
+		put(t, s, key, "1234", fcache.FileMeta{Size: 4})
+	}
+
+	list, err := pl.ListPrefix(context.Background(), "dir/")
+	require.NoError(t, err)
+
+	keys := make([]string, 0, len(list))
+	for _, meta := range list {
+		keys = append(keys, meta.Key)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"dir/key-1", "dir/key-2"}, keys)
+}
+
+// assertMeta checks the meta of the file, ignoring the case of its keys.
+func assertMeta(t *testing.T, expected map[string]string, meta fcache.FileMeta) {
+	t.Helper()
+	actual := make(map[string]string, len(meta.Meta))
+	for k, v := range meta.Meta {
+		actual[strings.ToLower(k)] = v
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func put(t *testing.T, s fcache.Store, key, content string, meta fcache.FileMeta) {
+	t.Helper()
+	require.NoError(t, s.Put(context.Background(), key, meta, io.NopCloser(strings.NewReader(content))))
+}
+
+func get(t *testing.T, s fcache.Store, key string) string {
+	t.Helper()
+	rd, err := s.Get(context.Background(), key)
+	require.NoError(t, err)
+	b, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.NoError(t, rd.Close())
+	return string(b)
+}
@@ -0,0 +1,78 @@
+package storetest
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Semior001/fcache"
+	"github.com/Semior001/fcache/fakes3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	Run(t, func(t *testing.T) fcache.Store {
+		return fcache.NewMemory(0, fcache.NopLogger())
+	}, WithUnknownSize(), WithMimeUpdate(), WithRemoveNotFound())
+}
+
+func TestFS(t *testing.T) {
+	Run(t, func(t *testing.T) fcache.Store {
+		store, err := fcache.NewFS(t.TempDir(), fcache.NopLogger())
+		require.NoError(t, err)
+		return store
+	}, WithUnknownSize(), WithMimeUpdate(), WithRemoveNotFound())
+}
+
+func TestS3(t *testing.T) {
+	for _, prefix := range []string{"", "prefix"} {
+		prefix := prefix
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			Run(t, func(t *testing.T) fcache.Store {
+				ts := httptest.NewServer(fakes3.New("bucket"))
+				t.Cleanup(ts.Close)
+
+				cl, err := minio.New(strings.TrimPrefix(ts.URL, "http://"), &minio.Options{
+					Creds: credentials.NewStaticV4("access-key", "secret-key", ""),
+				})
+				require.NoError(t, err)
+
+				return fcache.NewS3(cl, "bucket", prefix, fcache.NopLogger())
+			})
+		})
+	}
+}
+
+func TestTiered(t *testing.T) {
+	tbl := []struct {
+		name string
+		opts []fcache.TieredOption
+	}{
+		{name: "write-through"},
+		{name: "write-back", opts: []fcache.TieredOption{fcache.WithTieredWriteBack()}},
+		{name: "budget", opts: []fcache.TieredOption{fcache.WithTieredBudget(8, 2)}},
+	}
+
+	for _, tt := range tbl {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			Run(t, func(t *testing.T) fcache.Store {
+				l1, err := fcache.NewFS(t.TempDir(), fcache.NopLogger())
+				require.NoError(t, err)
+				return fcache.NewTiered(l1, fcache.NewMemory(0, fcache.NopLogger()), fcache.NopLogger(), tt.opts...)
+			}, WithUnknownSize(), WithMimeUpdate(), WithRemoveNotFound())
+		})
+	}
+}
+
+func TestSharded(t *testing.T) {
+	Run(t, func(t *testing.T) fcache.Store {
+		return fcache.NewSharded(map[string]fcache.Store{
+			"shard-1": fcache.NewMemory(0, fcache.NopLogger()),
+			"shard-2": fcache.NewMemory(0, fcache.NopLogger()),
+			"shard-3": fcache.NewMemory(0, fcache.NopLogger()),
+		}, fcache.NopLogger())
+	}, WithUnknownSize(), WithMimeUpdate(), WithRemoveNotFound())
+}
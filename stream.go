@@ -0,0 +1,118 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	streamBufSize           = 32 * 1024
+	defaultStreamPutTimeout = 5 * time.Minute
+)
+
+// streamFile loads the file with the request's loader and returns the reader,
+// which streams the content as it arrives from the loader, while the file is
+// being concurrently put into the store. The slowest side throttles the
+// other one. The loader and the put are not canceled along with the context,
+// so if the caller closes the reader early or cancels the context, the file
+// is still loaded and put into the store within StreamPutTimeout. Errors of
+// the loader and of the store are returned by the reader. finish is called
+// with the result of the put.
+func (l *LoadingCache) streamFile(
+	ctx context.Context,
+	req GetRequest,
+	finish func(FileMeta, error),
+) (io.ReadCloser, FileMeta, error) {
+	timeout := l.StreamPutTimeout
+	if timeout <= 0 {
+		timeout = defaultStreamPutTimeout
+	}
+	loadCtx, cancel := context.WithTimeout(detach(ctx), timeout)
+
+	originalRd, meta, err := l.callLoader(loadCtx, req)
+	if err != nil {
+		cancel()
+		err = fmt.Errorf("loader returned error: %w", err)
+		finish(FileMeta{}, err)
+		return nil, FileMeta{}, err
+	}
+
+	meta = l.loadedMeta(meta, req)
+
+	putRd, putWr := io.Pipe()
+	outRd, outWr := io.Pipe()
+
+	putErrCh := make(chan error, 1)
+	go func() {
+		err := l.traced(loadCtx, "fcache.Store.Put", req.Key, func(ctx context.Context) error {
+			return l.Store.Put(ctx, req.Key, copyMeta(meta), putRd)
+		})
+		if err != nil {
+			_ = putRd.CloseWithError(err)
+		} else {
+			// unblock the pump, in case if store didn't consume the whole file
+			_, _ = io.Copy(io.Discard, putRd)
+		}
+		putErrCh <- err
+	}()
+
+	go func() {
+		defer cancel()
+
+		err := pump(originalRd, putWr, outWr)
+		_ = putWr.CloseWithError(err)
+
+		if perr := <-putErrCh; perr != nil {
+			l.fail(ctx, OpPut, req.Key, meta, perr)
+			err = fmt.Errorf("put file into storage: %w", perr)
+		}
+
+		if cerr := originalRd.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close reader, received from loader: %w", cerr)
+		}
+
+		if err == nil {
+			l.stored(ctx, req.Key, meta)
+			l.loaded(req, meta)
+		}
+
+		finish(meta, err)
+		_ = outWr.CloseWithError(err)
+	}()
+
+	return outRd, meta, nil
+}
+
+// pump copies the content from src to both put and out writers.
+// Once out is closed by the reader, the content is copied only to put.
+func pump(src io.Reader, put, out *io.PipeWriter) error {
+	buf := make([]byte, streamBufSize)
+	outClosed := false
+
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			if _, err := put.Write(buf[:n]); err != nil {
+				return fmt.Errorf("write to store: %w", err)
+			}
+
+			if !outClosed {
+				if _, err := out.Write(buf[:n]); err != nil {
+					// caller has closed the reader, but the file still has
+					// to be put into the store
+					outClosed = true
+				}
+			}
+		}
+
+		if errors.Is(rerr, io.EOF) {
+			return nil
+		}
+		if rerr != nil {
+			return fmt.Errorf("read from loader: %w", rerr)
+		}
+	}
+}
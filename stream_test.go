@@ -0,0 +1,198 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_GetFile_Stream(t *testing.T) {
+	t.Run("streams before load is finished", func(t *testing.T) {
+		store := NewMemory(0, NopLogger())
+		svc := NewLoadingCache(store, WithLogger(NopLogger()))
+
+		loaderRd, loaderWr := io.Pipe()
+		rd, meta, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			TTL:    time.Minute,
+			Stream: true,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return loaderRd, FileMeta{Name: "a.txt"}, nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt", meta.Name)
+		assert.Contains(t, meta.Meta, metaInvalidateAtKey)
+
+		go func() {
+			_, _ = loaderWr.Write([]byte("some file "))
+		}()
+
+		buf := make([]byte, 10)
+		_, err = io.ReadFull(rd, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "some file ", string(buf))
+
+		go func() {
+			_, _ = loaderWr.Write([]byte("data"))
+			_ = loaderWr.Close()
+		}()
+
+		bts, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(bts))
+		require.NoError(t, rd.Close())
+
+		assertMemoryContent(t, store, "key", "some file data")
+		assert.Equal(t, CacheStats{Misses: 1}, svc.CacheStats)
+	})
+
+	t.Run("caller closes reader early", func(t *testing.T) {
+		store := NewMemory(0, NopLogger())
+		svc := NewLoadingCache(store, WithLogger(NopLogger()))
+
+		data := strings.Repeat("a", 3*streamBufSize)
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			Stream: true,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return io.NopCloser(strings.NewReader(data)), FileMeta{}, nil
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+
+		require.Eventually(t, func() bool {
+			_, err := store.Meta(context.Background(), "key")
+			return err == nil
+		}, time.Second, time.Millisecond)
+		assertMemoryContent(t, store, "key", data)
+	})
+
+	t.Run("caller cancels context", func(t *testing.T) {
+		stored := make(chan error, 1)
+		svc := NewLoadingCache(&StoreMock{
+			MetaFunc: func(ctx context.Context, key string) (FileMeta, error) { return FileMeta{}, ErrNotFound },
+			PutFunc: func(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+				_, err := io.ReadAll(rd)
+				if err == nil {
+					err = ctx.Err()
+				}
+				stored <- err
+				return err
+			},
+		}, WithLogger(NopLogger()))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		loaderRd, loaderWr := io.Pipe()
+		rd, _, err := svc.GetFile(ctx, GetRequest{
+			Key:    "key",
+			Stream: true,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				// the content is not received, once the context is canceled
+				go func() {
+					<-ctx.Done()
+					_ = loaderRd.CloseWithError(ctx.Err())
+				}()
+				return loaderRd, FileMeta{}, nil
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+		cancel()
+
+		_, _ = loaderWr.Write([]byte("some file data"))
+		require.NoError(t, loaderWr.Close())
+		assert.NoError(t, <-stored)
+	})
+
+	t.Run("store doesn't consume the whole file", func(t *testing.T) {
+		svc := NewLoadingCache(&StoreMock{
+			MetaFunc: func(ctx context.Context, key string) (FileMeta, error) { return FileMeta{}, ErrNotFound },
+			PutFunc: func(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+				_, err := rd.Read(make([]byte, 4))
+				return err
+			},
+		}, WithLogger(NopLogger()))
+
+		data := strings.Repeat("a", 3*streamBufSize)
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			Stream: true,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return io.NopCloser(strings.NewReader(data)), FileMeta{}, nil
+			},
+		})
+		require.NoError(t, err)
+
+		bts, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		assert.Equal(t, data, string(bts))
+		require.NoError(t, rd.Close())
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		svc := NewLoadingCache(&StoreMock{
+			MetaFunc: func(ctx context.Context, key string) (FileMeta, error) { return FileMeta{}, ErrNotFound },
+			PutFunc: func(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+				_, _ = rd.Read(make([]byte, 4))
+				return errors.New("store failure")
+			},
+		}, WithLogger(NopLogger()))
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			Stream: true,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return io.NopCloser(strings.NewReader(strings.Repeat("a", 3*streamBufSize))), FileMeta{}, nil
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(rd)
+		assert.EqualError(t, err, "put file into storage: store failure")
+		require.NoError(t, rd.Close())
+	})
+
+	t.Run("loader failure", func(t *testing.T) {
+		store := NewMemory(0, NopLogger())
+		svc := NewLoadingCache(store, WithLogger(NopLogger()))
+
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			Stream: true,
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return io.NopCloser(io.MultiReader(
+					strings.NewReader("some file data"),
+					&errReader{err: errors.New("connection reset")},
+				)), FileMeta{}, nil
+			},
+		})
+		require.NoError(t, err)
+
+		bts, err := io.ReadAll(rd)
+		assert.ErrorContains(t, err, "connection reset")
+		assert.Equal(t, "some file data", string(bts))
+
+		_, err = store.Meta(context.Background(), "key")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func assertMemoryContent(t *testing.T, store *Memory, key, expected string) {
+	rd, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	bts, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(bts))
+}
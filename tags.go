@@ -0,0 +1,123 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	metaTagsKey   = "_tags"
+	metaTagsDelim = ","
+)
+
+// Tags returns tags, attached to the file.
+func (m FileMeta) Tags() []string {
+	v, ok := m.Meta[metaTagsKey]
+	if !ok || v == "" {
+		return nil
+	}
+	return strings.Split(v, metaTagsDelim)
+}
+
+// WithTags returns the copy of meta with the given tags attached, in
+// addition to the present ones. Tags are kept sorted and unique, empty
+// tags are ignored. Tags must not contain commas.
+func (m FileMeta) WithTags(tags ...string) FileMeta {
+	if len(tags) == 0 {
+		return m
+	}
+
+	all := append(m.Tags(), tags...)
+	sort.Strings(all)
+
+	res := all[:0]
+	for i, tag := range all {
+		if tag != "" && (i == 0 || tag != all[i-1]) {
+			res = append(res, tag)
+		}
+	}
+
+	m = copyMeta(m)
+	m.Meta[metaTagsKey] = strings.Join(res, metaTagsDelim)
+	return m
+}
+
+// hasTag reports whether the file has the tag attached.
+func (m FileMeta) hasTag(tag string) bool {
+	for _, t := range m.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidateTag removes all files with the given tag attached.
+func (l *LoadingCache) InvalidateTag(ctx context.Context, tag string) (invalidated int64, err error) {
+	files, err := l.Store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list objects from store: %w", err)
+	}
+
+	var matched []FileMeta
+	for _, file := range files {
+		if file.hasTag(tag) {
+			matched = append(matched, file)
+		}
+	}
+
+	return l.removeAll(ctx, matched)
+}
+
+// InvalidatePrefix removes all files with keys, starting with the given
+// prefix. If the store implements PrefixLister, only the matching files are
+// listed.
+func (l *LoadingCache) InvalidatePrefix(ctx context.Context, prefix string) (invalidated int64, err error) {
+	var files []FileMeta
+
+	if pl, ok := l.Store.(PrefixLister); ok {
+		if files, err = pl.ListPrefix(ctx, prefix); err != nil {
+			return 0, fmt.Errorf("list objects with prefix from store: %w", err)
+		}
+	} else if files, err = l.Store.List(ctx); err != nil {
+		return 0, fmt.Errorf("list objects from store: %w", err)
+	}
+
+	var matched []FileMeta
+	for _, file := range files {
+		if strings.HasPrefix(file.Key, prefix) {
+			matched = append(matched, file)
+		}
+	}
+
+	return l.removeAll(ctx, matched)
+}
+
+// removeAll removes the given files from the store.
+// Files, which are already absent, are not counted.
+func (l *LoadingCache) removeAll(ctx context.Context, files []FileMeta) (removed int64, err error) {
+	errs := &multierror.Error{}
+
+	for _, file := range files {
+		l.access.remove(file.Key)
+		l.refreshes.forget(file.Key)
+
+		if err = l.Store.Remove(ctx, file.Key); err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				errs = multierror.Append(errs, fmt.Errorf("remove file under key %q: %w", file.Key, err))
+			}
+			continue
+		}
+
+		removed++
+		l.removed(ctx, file.Key, file, RemoveInvalidated)
+		l.log(ctx, LevelDebug, "removed file", StringAttr(LogKey, file.Key), StringAttr(LogOp, string(OpInvalidate)))
+	}
+
+	return removed, errs.ErrorOrNil()
+}
@@ -0,0 +1,98 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMeta_Tags(t *testing.T) {
+	meta := FileMeta{Meta: map[string]string{"k": "v"}}
+	assert.Empty(t, meta.Tags())
+
+	tagged := meta.WithTags("c", "a").WithTags("b", "a", "")
+	assert.Equal(t, []string{"a", "b", "c"}, tagged.Tags())
+	assert.Equal(t, map[string]string{"k": "v"}, meta.Meta, "original meta must not be modified")
+	assert.True(t, tagged.hasTag("b"))
+	assert.False(t, tagged.hasTag("d"))
+}
+
+func TestLoadingCache_InvalidateTag(t *testing.T) {
+	ctx := context.Background()
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+
+	for key, tags := range map[string][]string{
+		"key-1": {"customer-1", "thumbnail"},
+		"key-2": {"customer-2", "thumbnail"},
+		"key-3": {"customer-1"},
+		"key-4": nil,
+	} {
+		rd, _, err := svc.GetFile(ctx, GetRequest{Key: key, TTL: time.Hour, Tags: tags, Loader: stringLoader("data")})
+		require.NoError(t, err)
+		require.NoError(t, rd.Close())
+	}
+
+	invalidated, err := svc.InvalidateTag(ctx, "customer-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), invalidated)
+
+	keys, err := svc.Store.Keys(ctx)
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"key-2", "key-4"}, keys)
+}
+
+func TestLoadingCache_InvalidatePrefix(t *testing.T) {
+	t.Run("list fallback", func(t *testing.T) {
+		ctx := context.Background()
+		svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+
+		for _, key := range []string{"customer/1/a", "customer/1/b", "customer/10/a", "customer/2/a"} {
+			require.NoError(t, svc.Set(ctx, key, time.Hour, FileMeta{}, stringReader("data")))
+		}
+
+		invalidated, err := svc.InvalidatePrefix(ctx, "customer/1/")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), invalidated)
+
+		keys, err := svc.Store.Keys(ctx)
+		require.NoError(t, err)
+		sort.Strings(keys)
+		assert.Equal(t, []string{"customer/10/a", "customer/2/a"}, keys)
+	})
+
+	t.Run("prefix lister", func(t *testing.T) {
+		store := &prefixListerStore{StoreMock: &StoreMock{
+			RemoveFunc: func(ctx context.Context, key string) error {
+				if key == "customer/1/b" {
+					return errors.New("remove failure")
+				}
+				return nil
+			},
+		}}
+		store.listPrefix = func(ctx context.Context, prefix string) ([]FileMeta, error) {
+			assert.Equal(t, "customer/1/", prefix)
+			return []FileMeta{{Key: "customer/1/a"}, {Key: "customer/1/b"}}, nil
+		}
+		svc := NewLoadingCache(store, WithLogger(NopLogger()))
+
+		invalidated, err := svc.InvalidatePrefix(context.Background(), "customer/1/")
+		assert.EqualError(t, err, "1 error occurred:\n\t* remove file under key \"customer/1/b\": remove failure\n\n")
+		assert.Equal(t, int64(1), invalidated)
+		assert.Empty(t, store.ListCalls())
+	})
+}
+
+type prefixListerStore struct {
+	*StoreMock
+	listPrefix func(ctx context.Context, prefix string) ([]FileMeta, error)
+}
+
+func (s *prefixListerStore) ListPrefix(ctx context.Context, prefix string) ([]FileMeta, error) {
+	return s.listPrefix(ctx, prefix)
+}
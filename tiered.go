@@ -0,0 +1,704 @@
+package fcache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Tiered implements Store as a pair of stores: the fast local L1, e.g. FS,
+// in front of the shared L2, e.g. S3. Files are read from L1, falling back
+// to L2, L2 hits are promoted into L1. Files are written to both stores, or,
+// in write-back mode, to L1 only and flushed to L2 later by Run or Flush.
+// L1 keeps files within its own budget, evicting least recently read ones,
+// regardless of their TTL, dirty files are never evicted.
+type Tiered struct {
+	log       Logger
+	l1, l2    Store
+	writeBack bool
+	maxSize   int64
+	maxKeys   int
+
+	keys keyLocks // serializes puts, removals, flushes and promotions of the same key
+
+	mu        sync.Mutex
+	indexed   bool
+	items     map[string]*list.Element // files in L1
+	lru       *list.List               // front is the most recently used file
+	size      int64
+	dirty     map[string]int64 // sizes of files, not flushed to L2 yet
+	dirtySize int64
+	notify    chan struct{}
+}
+
+type tieredItem struct {
+	key  string
+	size int64
+}
+
+// TieredOption customizes Tiered.
+type TieredOption func(t *Tiered)
+
+// WithTieredBudget limits the total size of files in L1 in bytes and their
+// number. Zero means no limit. Files, bigger than maxSize, aren't put into
+// L1 at all.
+// No limits by default.
+func WithTieredBudget(maxSize int64, maxKeys int) TieredOption {
+	return func(t *Tiered) {
+		t.maxSize = maxSize
+		t.maxKeys = maxKeys
+	}
+}
+
+// WithTieredWriteBack makes Tiered put files only into L1, they are flushed
+// to L2 by Run or Flush. Files, which are not flushed before exit, are kept
+// only in L1, so Flush should be called on shutdown.
+// Files are written to both stores by default.
+func WithTieredWriteBack() TieredOption {
+	return func(t *Tiered) { t.writeBack = true }
+}
+
+// NewTiered makes new instance of Tiered.
+func NewTiered(l1, l2 Store, log Logger, opts ...TieredOption) *Tiered {
+	res := &Tiered{
+		log:    log,
+		l1:     l1,
+		l2:     l2,
+		items:  map[string]*list.Element{},
+		lru:    list.New(),
+		dirty:  map[string]int64{},
+		notify: make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	return res
+}
+
+// Meta returns meta information about the file at underlying key.
+func (t *Tiered) Meta(ctx context.Context, key string) (FileMeta, error) {
+	meta, err := t.l1.Meta(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return meta, err
+	}
+
+	return t.l2.Meta(ctx, key)
+}
+
+// UpdateMeta updates meta information about the file in both stores.
+func (t *Tiered) UpdateMeta(ctx context.Context, key string, meta FileMeta) error {
+	unlock := t.keys.lock(key)
+	defer unlock()
+
+	err1 := t.l1.UpdateMeta(ctx, key, meta)
+	if err1 != nil && !errors.Is(err1, ErrNotFound) {
+		return fmt.Errorf("update meta in l1: %w", err1)
+	}
+
+	if t.isDirty(key) {
+		// meta is flushed to l2 along with the file
+		return err1
+	}
+
+	err2 := t.l2.UpdateMeta(ctx, key, meta)
+	switch {
+	case errors.Is(err2, ErrNotFound):
+		return err1
+	case err2 != nil:
+		return fmt.Errorf("update meta in l2: %w", err2)
+	}
+
+	return nil
+}
+
+// Get returns the reader of the file's content from L1, or from L2,
+// promoting the file into L1 in background. The file isn't promoted, if
+// it's being put, removed or promoted concurrently.
+func (t *Tiered) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	rd, err := t.getL1(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return rd, err
+	}
+
+	if rd, err = t.l2.Get(ctx, key); err != nil {
+		return nil, err
+	}
+
+	t.promote(detach(ctx), key)
+	return rd, nil
+}
+
+// GetRange returns the reader of the file's part from L1, or from L2
+// without promoting the file, as only the part of it is read.
+func (t *Tiered) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	rd, err := getRange(ctx, t.l1, key, offset, length)
+	if err == nil {
+		t.touch(key)
+		return rd, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("get range from l1: %w", err)
+	}
+
+	return getRange(ctx, t.l2, key, offset, length)
+}
+
+// GetURL returns the URL of the file from L2, as it's shared, or from L1,
+// if L2 doesn't provide it.
+func (t *Tiered) GetURL(ctx context.Context, key string, params GetURLParams) (string, error) {
+	u, err := t.l2.GetURL(ctx, key, params)
+	if err == nil {
+		return u, nil
+	}
+
+	if u, err1 := t.l1.GetURL(ctx, key, params); err1 == nil {
+		return u, nil
+	}
+
+	return "", err
+}
+
+// Put puts file into L1 and L2, or only into L1 in write-back mode.
+// The file is put directly into L2, if it exceeds the L1 budget.
+func (t *Tiered) Put(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser) error {
+	unlock := t.keys.lock(key)
+	defer unlock()
+
+	if !t.fits(meta.Size) {
+		t.drop(ctx, key)
+		if err := t.l2.Put(ctx, key, meta, rd); err != nil {
+			return fmt.Errorf("put file into l2: %w", err)
+		}
+		return nil
+	}
+
+	// file is dirty until it's copied to L2, so it's not evicted before
+	if err := t.putL1(ctx, key, meta, rd, true); err != nil {
+		return err
+	}
+
+	if t.writeBack {
+		select {
+		case t.notify <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	if err := t.flush(ctx, key); err != nil {
+		t.drop(ctx, key)
+		return err
+	}
+
+	t.evict(ctx)
+	return nil
+}
+
+// Remove removes file from both stores.
+// It returns ErrNotFound, if the file is absent in both of them.
+func (t *Tiered) Remove(ctx context.Context, key string) error {
+	unlock := t.keys.lock(key)
+	defer unlock()
+
+	t.mu.Lock()
+	t.forget(key)
+	t.mu.Unlock()
+
+	err1 := t.l1.Remove(ctx, key)
+	if err1 != nil && !errors.Is(err1, ErrNotFound) {
+		return fmt.Errorf("remove file from l1: %w", err1)
+	}
+
+	err2 := t.l2.Remove(ctx, key)
+	switch {
+	case errors.Is(err2, ErrNotFound):
+		return err1
+	case err2 != nil:
+		return fmt.Errorf("remove file from l2: %w", err2)
+	}
+
+	return nil
+}
+
+// Stat returns stats of L2 along with files, which are not flushed yet.
+// Files, which are overwritten in L1 and not flushed yet, are counted twice.
+func (t *Tiered) Stat(ctx context.Context) (StoreStats, error) {
+	res, err := t.l2.Stat(ctx)
+	if err != nil {
+		return StoreStats{}, fmt.Errorf("get stats of l2: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res.Keys += len(t.dirty)
+	res.Size += t.dirtySize
+
+	return res, nil
+}
+
+// Keys returns keys of L2 along with keys of files, which are not
+// flushed yet.
+func (t *Tiered) Keys(ctx context.Context) ([]string, error) {
+	keys, err := t.l2.Keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get keys of l2: %w", err)
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.dirty {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// List lists files of L2 along with files, which are not flushed yet.
+func (t *Tiered) List(ctx context.Context) ([]FileMeta, error) {
+	files, err := t.l2.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list files of l2: %w", err)
+	}
+
+	return t.withDirty(ctx, files, "")
+}
+
+// ListPrefix lists files of L2 with keys, starting with the prefix, along
+// with such files, which are not flushed yet. All files of L2 are listed,
+// if it doesn't implement PrefixLister.
+func (t *Tiered) ListPrefix(ctx context.Context, prefix string) ([]FileMeta, error) {
+	files, err := listPrefix(ctx, t.l2, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list files of l2: %w", err)
+	}
+
+	return t.withDirty(ctx, files, prefix)
+}
+
+// withDirty adds files with the prefix, which are not flushed yet, to the
+// files of L2, replacing their flushed versions.
+func (t *Tiered) withDirty(ctx context.Context, files []FileMeta, prefix string) ([]FileMeta, error) {
+	idx := make(map[string]int, len(files))
+	for i, file := range files {
+		idx[file.Key] = i
+	}
+
+	for _, key := range t.dirtyKeys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		meta, err := t.l1.Meta(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue // flushed and evicted concurrently
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get meta of unflushed file %s: %w", key, err)
+		}
+
+		meta.Key = key
+		if i, ok := idx[key]; ok {
+			files[i] = meta
+			continue
+		}
+		files = append(files, meta)
+	}
+
+	return files, nil
+}
+
+// Run flushes files to L2 in background, as they are put, until the
+// context is canceled. Failed files are flushed again on the next put
+// or by Flush.
+func (t *Tiered) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.notify:
+		}
+
+		if err := t.Flush(ctx); err != nil {
+			t.log.Printf("[WARN] failed to flush files to l2: %v", err)
+		}
+	}
+}
+
+// Flush puts all files, which have been put in write-back mode, into L2.
+func (t *Tiered) Flush(ctx context.Context) error {
+	errs := &multierror.Error{}
+
+	for _, key := range t.dirtyKeys() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		unlock := t.keys.lock(key)
+		err := t.flush(ctx, key)
+		unlock()
+
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("flush %s: %w", key, err))
+		}
+	}
+
+	// flushed files may be evicted now
+	t.evict(ctx)
+
+	return errs.ErrorOrNil()
+}
+
+// Evict removes least recently read files from L1, until it fits its
+// budget. Files are evicted on put, thus it's needed only to apply the
+// budget to files, which were in L1 before start.
+func (t *Tiered) Evict(ctx context.Context) error {
+	if err := t.index(ctx); err != nil {
+		return err
+	}
+
+	t.evict(ctx)
+	return nil
+}
+
+// getL1 returns the reader of the file's content from L1.
+func (t *Tiered) getL1(ctx context.Context, key string) (io.ReadCloser, error) {
+	rd, err := t.l1.Get(ctx, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("get file from l1: %w", err)
+	}
+
+	t.touch(key)
+	return rd, nil
+}
+
+// promote copies the file from L2 into L1 in background, reading it from
+// L2 separately, so the key isn't locked for the time the caller reads it.
+func (t *Tiered) promote(ctx context.Context, key string) {
+	unlock, ok := t.keys.tryLock(key)
+	if !ok {
+		return
+	}
+
+	go func() {
+		defer unlock()
+
+		if err := t.copyToL1(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+			t.log.Printf("[WARN] failed to promote file %s into l1: %v", key, err)
+		}
+	}()
+}
+
+// putL1 puts the file into L1 and indexes it. Caller must evict files
+// after that, if the budget is exceeded.
+func (t *Tiered) putL1(ctx context.Context, key string, meta FileMeta, rd io.ReadCloser, dirty bool) error {
+	if err := t.index(ctx); err != nil {
+		_ = rd.Close()
+		return err
+	}
+
+	if err := t.l1.Put(ctx, key, meta, rd); err != nil {
+		return fmt.Errorf("put file into l1: %w", err)
+	}
+
+	// size might be unknown before put
+	stored, err := t.l1.Meta(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get meta of file in l1: %w", err)
+	}
+
+	t.mu.Lock()
+	t.forget(key)
+	t.items[key] = t.lru.PushFront(&tieredItem{key: key, size: stored.Size})
+	t.size += stored.Size
+	if dirty {
+		t.dirty[key] = stored.Size
+		t.dirtySize += stored.Size
+	}
+	t.mu.Unlock()
+
+	return nil
+}
+
+// flush puts the dirty file from L1 into L2 and marks it as clean.
+// The key must be locked.
+func (t *Tiered) flush(ctx context.Context, key string) error {
+	if !t.isDirty(key) {
+		return nil // removed or flushed concurrently
+	}
+
+	if err := t.copyToL2(ctx, key); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.clean(key)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// copyToL1 puts the file from L2 into L1, if it fits the budget.
+// The key must be locked.
+func (t *Tiered) copyToL1(ctx context.Context, key string) error {
+	// file might be put before the key has been locked
+	if _, err := t.l1.Meta(ctx, key); err == nil {
+		return nil
+	}
+
+	meta, err := t.l2.Meta(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get meta of file in l2: %w", err)
+	}
+
+	if !t.fits(meta.Size) {
+		return nil
+	}
+
+	rd, err := t.l2.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get file from l2: %w", err)
+	}
+
+	if err = t.putL1(ctx, key, meta, rd, false); err != nil {
+		return err
+	}
+
+	t.evict(ctx)
+	return nil
+}
+
+// copyToL2 puts the file from L1 into L2.
+func (t *Tiered) copyToL2(ctx context.Context, key string) error {
+	meta, err := t.l1.Meta(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get meta of file in l1: %w", err)
+	}
+
+	rd, err := t.l1.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get file from l1: %w", err)
+	}
+
+	if err = t.l2.Put(ctx, key, meta, rd); err != nil {
+		return fmt.Errorf("put file into l2: %w", err)
+	}
+
+	return nil
+}
+
+// index loads the list of files, which are already in L1, once.
+func (t *Tiered) index(ctx context.Context) error {
+	t.mu.Lock()
+	indexed := t.indexed
+	t.mu.Unlock()
+
+	if indexed {
+		return nil
+	}
+
+	files, err := t.l1.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list files of l1: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.indexed {
+		return nil
+	}
+
+	for _, file := range files {
+		if _, ok := t.items[file.Key]; !ok {
+			t.items[file.Key] = t.lru.PushBack(&tieredItem{key: file.Key, size: file.Size})
+			t.size += file.Size
+		}
+	}
+	t.indexed = true
+
+	return nil
+}
+
+// evict removes least recently used files from L1, while it exceeds
+// its budget.
+func (t *Tiered) evict(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		var victim *tieredItem
+		if t.exceeded() {
+			victim = t.victim()
+		}
+		if victim != nil {
+			t.forget(victim.key)
+		}
+		t.mu.Unlock()
+
+		if victim == nil {
+			return
+		}
+
+		if err := t.l1.Remove(ctx, victim.key); err != nil && !errors.Is(err, ErrNotFound) {
+			t.log.Printf("[WARN] failed to evict file %s from l1: %v", victim.key, err)
+		}
+	}
+}
+
+// victim returns the file to evict: the one, which doesn't fit the budget
+// on its own, e.g. put with unknown size, or the least recently used one.
+// Dirty files are skipped. Must be called under lock.
+func (t *Tiered) victim() *tieredItem {
+	var lru *tieredItem
+	for el := t.lru.Back(); el != nil; el = el.Prev() {
+		item := el.Value.(*tieredItem)
+		_, dirty := t.dirty[item.key]
+		switch {
+		case dirty:
+		case !t.fits(item.size):
+			return item
+		case lru == nil:
+			lru = item
+		}
+	}
+	return lru
+}
+
+// drop removes the stale copy of the file from L1.
+func (t *Tiered) drop(ctx context.Context, key string) {
+	t.mu.Lock()
+	t.forget(key)
+	t.mu.Unlock()
+
+	if err := t.l1.Remove(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+		t.log.Printf("[WARN] failed to remove file %s from l1: %v", key, err)
+	}
+}
+
+// touch marks the file in L1 as recently used.
+func (t *Tiered) touch(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if el, ok := t.items[key]; ok {
+		t.lru.MoveToFront(el)
+	}
+}
+
+// forget removes the file from the index, must be called under lock.
+func (t *Tiered) forget(key string) {
+	t.clean(key)
+
+	el, ok := t.items[key]
+	if !ok {
+		return
+	}
+
+	t.lru.Remove(el)
+	delete(t.items, key)
+	t.size -= el.Value.(*tieredItem).size
+}
+
+// clean marks the file as flushed, must be called under lock.
+func (t *Tiered) clean(key string) {
+	if size, ok := t.dirty[key]; ok {
+		t.dirtySize -= size
+		delete(t.dirty, key)
+	}
+}
+
+// exceeded reports whether L1 exceeds its budget, must be called under lock.
+func (t *Tiered) exceeded() bool {
+	return (t.maxSize > 0 && t.size > t.maxSize) || (t.maxKeys > 0 && len(t.items) > t.maxKeys)
+}
+
+// fits reports whether the file of the given size may be put into L1.
+func (t *Tiered) fits(size int64) bool {
+	return t.maxSize <= 0 || size <= t.maxSize
+}
+
+func (t *Tiered) isDirty(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.dirty[key]
+	return ok
+}
+
+func (t *Tiered) dirtyKeys() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]string, 0, len(t.dirty))
+	for key := range t.dirty {
+		res = append(res, key)
+	}
+	return res
+}
+
+// listPrefix lists files with keys, starting with the prefix, filtering all
+// files of the store, if it doesn't implement PrefixLister.
+func listPrefix(ctx context.Context, s Store, prefix string) ([]FileMeta, error) {
+	if pl, ok := s.(PrefixLister); ok {
+		return pl.ListPrefix(ctx, prefix)
+	}
+
+	files, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := files[:0]
+	for _, file := range files {
+		if strings.HasPrefix(file.Key, prefix) {
+			res = append(res, file)
+		}
+	}
+
+	return res, nil
+}
+
+// getRange returns the reader of the file's part, reading it from the
+// beginning, if the store doesn't implement RangeGetter.
+func getRange(ctx context.Context, s Store, key string, offset, length int64) (io.ReadCloser, error) {
+	if rg, ok := s.(RangeGetter); ok {
+		return rg.GetRange(ctx, key, offset, length)
+	}
+
+	rd, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.CopyN(io.Discard, rd, offset); err != nil && !errors.Is(err, io.EOF) {
+		_ = rd.Close()
+		return nil, fmt.Errorf("skip %d bytes: %w", offset, err)
+	}
+
+	var res io.Reader = rd
+	if length >= 0 {
+		res = io.LimitReader(rd, length)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{Reader: res, Closer: rd}, nil
+}
@@ -0,0 +1,197 @@
+package fcache
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+
+	prepare := func(opts ...TieredOption) (svc *Tiered, l1, l2 *Memory) {
+		l1, l2 = NewMemory(0, NopLogger()), NewMemory(0, NopLogger())
+		return NewTiered(l1, l2, NopLogger(), opts...), l1, l2
+	}
+
+	keys := func(t *testing.T, s Store) []string {
+		res, err := s.Keys(ctx)
+		require.NoError(t, err)
+		sort.Strings(res)
+		return res
+	}
+
+	t.Run("promote", func(t *testing.T) {
+		svc, l1, l2 := prepare()
+		require.NoError(t, l2.Put(ctx, "key", FileMeta{Name: "a.txt"}, stringReader("1234")))
+
+		rd, err := svc.Get(ctx, "key")
+		require.NoError(t, err)
+		assertReader(t, rd, "1234")
+
+		// file is promoted in background
+		require.Eventually(t, func() bool {
+			_, err := l1.Meta(ctx, "key")
+			return err == nil
+		}, time.Second, time.Millisecond)
+		assertMemoryContent(t, l1, "key", "1234")
+		meta, err := l1.Meta(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt", meta.Name)
+
+		// range reads don't promote
+		require.NoError(t, l2.Put(ctx, "range", FileMeta{}, stringReader("0123456789")))
+		rd, err = svc.GetRange(ctx, "range", 3, 4)
+		require.NoError(t, err)
+		assertReader(t, rd, "3456")
+		assert.Equal(t, []string{"key"}, keys(t, l1))
+	})
+
+	t.Run("unread file doesn't block writers", func(t *testing.T) {
+		svc, l1, l2 := prepare()
+		require.NoError(t, l2.Put(ctx, "key", FileMeta{}, stringReader("1234")))
+
+		rd, err := svc.Get(ctx, "key")
+		require.NoError(t, err)
+		defer rd.Close()
+
+		require.NoError(t, svc.UpdateMeta(ctx, "key", FileMeta{Name: "a.txt"}))
+		require.NoError(t, svc.Put(ctx, "key", FileMeta{}, stringReader("5678")))
+		assertMemoryContent(t, l1, "key", "5678")
+		assertMemoryContent(t, l2, "key", "5678")
+	})
+
+	t.Run("loading cache extends ttl of file in l2", func(t *testing.T) {
+		store, _, l2 := prepare()
+		svc := NewLoadingCache(store, WithLogger(NopLogger()))
+		svc.ExtendTTL = true
+
+		require.NoError(t, l2.Put(ctx, "key", FileMeta{}.WithExpiration(time.Now().Add(time.Minute)),
+			stringReader("1234")))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			rd, _, err := svc.GetFile(ctx, GetRequest{Key: "key", TTL: time.Hour})
+			assert.NoError(t, err)
+			assertReader(t, rd, "1234")
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			require.FailNow(t, "GetFile is blocked")
+		}
+
+		meta, err := l2.Meta(ctx, "key")
+		require.NoError(t, err)
+		expiresAt, ok := meta.ExpiresAt()
+		require.True(t, ok)
+		assert.True(t, expiresAt.After(time.Now().Add(30*time.Minute)), "ttl must be extended")
+	})
+
+	t.Run("write-through", func(t *testing.T) {
+		svc, l1, l2 := prepare()
+		require.NoError(t, svc.Put(ctx, "key", FileMeta{}, stringReader("1234")))
+
+		assertMemoryContent(t, l1, "key", "1234")
+		assertMemoryContent(t, l2, "key", "1234")
+
+		require.NoError(t, svc.UpdateMeta(ctx, "key", FileMeta{Name: "b.txt"}))
+		for _, s := range []Store{l1, l2} {
+			meta, err := s.Meta(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "b.txt", meta.Name)
+		}
+
+		require.NoError(t, svc.Remove(ctx, "key"))
+		assert.Empty(t, keys(t, l1))
+		assert.Empty(t, keys(t, l2))
+		assert.ErrorIs(t, svc.Remove(ctx, "key"), ErrNotFound)
+	})
+
+	t.Run("write-back", func(t *testing.T) {
+		svc, l1, l2 := prepare(WithTieredWriteBack(), WithTieredBudget(0, 1))
+		require.NoError(t, svc.Put(ctx, "key-1", FileMeta{}, stringReader("1234")))
+		require.NoError(t, svc.Put(ctx, "key-2", FileMeta{}, stringReader("5678")))
+
+		// dirty files are not evicted
+		assert.Equal(t, []string{"key-1", "key-2"}, keys(t, l1))
+		assert.Empty(t, keys(t, l2))
+		assert.Equal(t, []string{"key-1", "key-2"}, keys(t, svc))
+
+		stat, err := svc.Stat(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, StoreStats{Keys: 2, Size: 8}, stat)
+
+		require.NoError(t, svc.Flush(ctx))
+		assert.Equal(t, []string{"key-1", "key-2"}, keys(t, l2))
+		assert.Len(t, keys(t, l1), 1)
+
+		rd, err := svc.Get(ctx, "key-1")
+		require.NoError(t, err)
+		assertReader(t, rd, "1234")
+	})
+
+	t.Run("run", func(t *testing.T) {
+		svc, _, l2 := prepare(WithTieredWriteBack())
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.ErrorIs(t, svc.Run(ctx), context.Canceled)
+		}()
+
+		require.NoError(t, svc.Put(ctx, "key", FileMeta{}, stringReader("1234")))
+		require.Eventually(t, func() bool {
+			_, err := l2.Meta(ctx, "key")
+			return err == nil
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		svc, l1, l2 := prepare(WithTieredBudget(8, 0))
+
+		for _, key := range []string{"key-1", "key-2"} {
+			require.NoError(t, svc.Put(ctx, key, FileMeta{Size: 4}, stringReader("1234")))
+		}
+
+		// key-1 is the most recently used
+		rd, err := svc.Get(ctx, "key-1")
+		require.NoError(t, err)
+		assertReader(t, rd, "1234")
+
+		require.NoError(t, svc.Put(ctx, "key-3", FileMeta{Size: 4}, stringReader("1234")))
+		assert.Equal(t, []string{"key-1", "key-3"}, keys(t, l1))
+
+		// file, bigger than the budget, is put only into l2
+		require.NoError(t, svc.Put(ctx, "big", FileMeta{Size: 10}, stringReader("0123456789")))
+		assert.Equal(t, []string{"key-1", "key-3"}, keys(t, l1))
+		assert.Equal(t, []string{"big", "key-1", "key-2", "key-3"}, keys(t, l2))
+
+		// file of unknown size is evicted right after put
+		require.NoError(t, svc.Put(ctx, "unknown", FileMeta{}, stringReader("0123456789")))
+		assert.Equal(t, []string{"key-1", "key-3"}, keys(t, l1))
+		assertMemoryContent(t, l2, "unknown", "0123456789")
+	})
+
+	t.Run("evict files, present before start", func(t *testing.T) {
+		svc, l1, l2 := prepare(WithTieredBudget(0, 1))
+		for _, key := range []string{"key-1", "key-2"} {
+			require.NoError(t, l1.Put(ctx, key, FileMeta{}, stringReader("1234")))
+			require.NoError(t, l2.Put(ctx, key, FileMeta{}, stringReader("1234")))
+		}
+
+		require.NoError(t, svc.Evict(ctx))
+		assert.Len(t, keys(t, l1), 1)
+		assert.Len(t, keys(t, l2), 2)
+	})
+}
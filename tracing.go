@@ -0,0 +1,98 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+)
+
+// Tracer starts spans of cache and store operations.
+// Implementations must be safe for concurrent use.
+type Tracer interface {
+	// Start starts the span as a child of the span in the context, if any,
+	// and returns the context with the started span.
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	RecordError(err error)
+	End()
+}
+
+// Attr is a key-value attribute of the span or log record.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// StringAttr makes a string attribute.
+func StringAttr(key, val string) Attr { return Attr{Key: key, Value: val} }
+
+// Int64Attr makes an integer attribute.
+func Int64Attr(key string, val int64) Attr { return Attr{Key: key, Value: val} }
+
+// BoolAttr makes a boolean attribute.
+func BoolAttr(key string, val bool) Attr { return Attr{Key: key, Value: val} }
+
+// Attribute keys, used in spans.
+const (
+	AttrKey    = "fcache.key"    // key of the file
+	AttrSize   = "fcache.size"   // size of the file
+	AttrResult = "fcache.result" // hit, stale, miss or coalesced
+	AttrCount  = "fcache.count"  // number of affected files
+)
+
+const resultCoalesced = "coalesced"
+
+// result returns the value of the span's result attribute for the state.
+func (s entryState) result() string {
+	switch s {
+	case stateFresh:
+		return "hit"
+	case stateStale:
+		return "stale"
+	default:
+		return "miss"
+	}
+}
+
+type (
+	nopTracer struct{}
+	nopSpan   struct{}
+)
+
+func (nopTracer) Start(ctx context.Context, _ string, _ ...Attr) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+func (nopSpan) SetAttributes(...Attr) {}
+func (nopSpan) RecordError(error)     {}
+func (nopSpan) End()                  {}
+
+func (l *LoadingCache) tracer() Tracer {
+	if l.Tracer == nil {
+		return nopTracer{}
+	}
+	return l.Tracer
+}
+
+// traced runs fn within the span with the given name, attributed with the
+// file's key. ErrNotFound is not recorded as the span's error.
+func (l *LoadingCache) traced(ctx context.Context, name, key string, fn func(ctx context.Context) error) error {
+	ctx, span := l.tracer().Start(ctx, name, StringAttr(AttrKey, key))
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan records the error, unless it is ErrNotFound, and ends the span.
+func endSpan(span Span, err error, attrs ...Attr) {
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUncacheable) {
+		span.RecordError(err)
+	}
+	span.End()
+}
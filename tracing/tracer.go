@@ -0,0 +1,76 @@
+// Package tracing provides the OpenTelemetry tracer for fcache.LoadingCache
+// and fcache.S3.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Semior001/fcache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Semior001/fcache"
+
+// Tracer implements fcache.Tracer with OpenTelemetry.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer makes new instance of Tracer with the tracer from the given
+// provider. It should be passed to the cache with fcache.WithTracer and
+// to the S3 store with fcache.WithS3Tracer.
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Start implements fcache.Tracer.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...fcache.Attr) (context.Context, fcache.Span) {
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(convert(attrs)...),
+	)
+	return ctx, Span{span: span}
+}
+
+// Span implements fcache.Span with OpenTelemetry.
+type Span struct {
+	span trace.Span
+}
+
+// SetAttributes implements fcache.Span.
+func (s Span) SetAttributes(attrs ...fcache.Attr) { s.span.SetAttributes(convert(attrs)...) }
+
+// RecordError implements fcache.Span, it also sets the error status.
+func (s Span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End implements fcache.Span.
+func (s Span) End() { s.span.End() }
+
+func convert(attrs []fcache.Attr) []attribute.KeyValue {
+	res := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		switch v := attr.Value.(type) {
+		case string:
+			res = append(res, attribute.String(attr.Key, v))
+		case int64:
+			res = append(res, attribute.Int64(attr.Key, v))
+		case int:
+			res = append(res, attribute.Int(attr.Key, v))
+		case bool:
+			res = append(res, attribute.Bool(attr.Key, v))
+		case float64:
+			res = append(res, attribute.Float64(attr.Key, v))
+		case fmt.Stringer:
+			res = append(res, attribute.Stringer(attr.Key, v))
+		default:
+			res = append(res, attribute.String(attr.Key, fmt.Sprint(v)))
+		}
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Semior001/fcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+
+	svc := fcache.NewLoadingCache(fcache.NewMemory(0, fcache.NopLogger()),
+		fcache.WithLogger(fcache.NopLogger()),
+		fcache.WithTracer(NewTracer(tp)),
+	)
+
+	loaderErr := errors.New("origin failure")
+	_, _, err := svc.GetFile(context.Background(), fcache.GetRequest{
+		Key: "key",
+		TTL: time.Hour,
+		Loader: func(ctx context.Context) (io.ReadCloser, fcache.FileMeta, error) {
+			return nil, fcache.FileMeta{}, loaderErr
+		},
+	})
+	require.ErrorIs(t, err, loaderErr)
+
+	rd, _, err := svc.GetFile(context.Background(), fcache.GetRequest{
+		Key: "key",
+		TTL: time.Hour,
+		Loader: func(ctx context.Context) (io.ReadCloser, fcache.FileMeta, error) {
+			return io.NopCloser(strings.NewReader("1234")), fcache.FileMeta{Size: 4}, nil
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, rd.Close())
+
+	spans := rec.Ended()
+	require.Len(t, spans, 7)
+
+	loader := spans[1]
+	assert.Equal(t, "fcache.Loader", loader.Name())
+	assert.Equal(t, codes.Error, loader.Status().Code)
+	assert.Equal(t, "origin failure", loader.Status().Description)
+	require.Len(t, loader.Events(), 1)
+	assert.Equal(t, "exception", loader.Events()[0].Name)
+
+	getFile := spans[6]
+	assert.Equal(t, "fcache.GetFile", getFile.Name())
+	assert.Equal(t, codes.Unset, getFile.Status().Code)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String(fcache.AttrKey, "key"),
+		attribute.String(fcache.AttrResult, "miss"),
+		attribute.Int64(fcache.AttrSize, 4),
+	}, getFile.Attributes())
+
+	for _, span := range spans[3:6] {
+		assert.Equal(t, getFile.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+	}
+}
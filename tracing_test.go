@@ -0,0 +1,134 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCache_Tracer(t *testing.T) {
+	tr := &tracerRecorder{}
+	svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()), WithTracer(tr))
+
+	for i := 0; i < 2; i++ {
+		rd, _, err := svc.GetFile(context.Background(), GetRequest{
+			Key:    "key",
+			TTL:    time.Hour,
+			Loader: stringLoader("1234"),
+		})
+		require.NoError(t, err)
+		assertReader(t, rd, "1234")
+	}
+
+	assert.Equal(t, []recordedSpan{
+		{name: "fcache.Store.Meta", parent: "fcache.GetFile", attrs: map[string]interface{}{AttrKey: "key"}},
+		{
+			name:   "fcache.Loader",
+			parent: "fcache.GetFile",
+			attrs:  map[string]interface{}{AttrKey: "key", AttrSize: int64(4)},
+		},
+		{name: "fcache.Store.Put", parent: "fcache.GetFile", attrs: map[string]interface{}{AttrKey: "key"}},
+		{
+			name:  "fcache.GetFile",
+			attrs: map[string]interface{}{AttrKey: "key", AttrResult: "miss", AttrSize: int64(4)},
+		},
+		{name: "fcache.Store.Meta", parent: "fcache.GetFile", attrs: map[string]interface{}{AttrKey: "key"}},
+		{name: "fcache.Store.Get", parent: "fcache.GetFile", attrs: map[string]interface{}{AttrKey: "key"}},
+		{
+			name:  "fcache.GetFile",
+			attrs: map[string]interface{}{AttrKey: "key", AttrResult: "hit", AttrSize: int64(4)},
+		},
+	}, tr.spans)
+
+	t.Run("error is recorded", func(t *testing.T) {
+		tr.spans = nil
+		loaderErr := errors.New("origin failure")
+		_, _, err := svc.GetURL(context.Background(), GetRequest{
+			Key: "failed",
+			Loader: func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+				return nil, FileMeta{}, loaderErr
+			},
+		}, GetURLParams{})
+		require.Error(t, err)
+
+		require.Len(t, tr.spans, 3)
+		assert.Equal(t, "fcache.Store.Meta", tr.spans[0].name)
+		assert.NoError(t, tr.spans[0].err, "not found must not be recorded")
+		assert.Equal(t, "fcache.Loader", tr.spans[1].name)
+		assert.Equal(t, loaderErr, tr.spans[1].err)
+		assert.Equal(t, "fcache.GetURL", tr.spans[2].name)
+		assert.ErrorIs(t, tr.spans[2].err, loaderErr)
+	})
+}
+
+func TestS3_Tracer(t *testing.T) {
+	tr := &tracerRecorder{}
+	svc := &S3{
+		cl: &s3clientMock{
+			RemoveObjectFunc: func(ctx context.Context, bkt, key string, opts minio.RemoveObjectOptions) error {
+				assert.Equal(t, "s3.RemoveObject", ctx.Value(spanNameKey{}))
+				return nil
+			},
+		},
+		tracer: tr,
+		bucket: "bucket",
+		prefix: "prefix",
+	}
+
+	require.NoError(t, svc.Remove(context.Background(), "key"))
+	assert.Equal(t, []recordedSpan{{
+		name:  "s3.RemoveObject",
+		attrs: map[string]interface{}{"s3.bucket": "bucket", "s3.key": "prefix!!key"},
+	}}, tr.spans)
+}
+
+type spanNameKey struct{}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+}
+
+// tracerRecorder records ended spans.
+type tracerRecorder struct {
+	mu    sync.Mutex
+	spans []recordedSpan
+}
+
+func (r *tracerRecorder) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	parent, _ := ctx.Value(spanNameKey{}).(string)
+	span := &spanRecorder{tr: r, span: recordedSpan{name: name, parent: parent}}
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanNameKey{}, name), span
+}
+
+type spanRecorder struct {
+	tr   *tracerRecorder
+	span recordedSpan
+}
+
+func (s *spanRecorder) SetAttributes(attrs ...Attr) {
+	if s.span.attrs == nil {
+		s.span.attrs = map[string]interface{}{}
+	}
+	for _, attr := range attrs {
+		s.span.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *spanRecorder) RecordError(err error) { s.span.err = err }
+
+func (s *spanRecorder) End() {
+	s.tr.mu.Lock()
+	defer s.tr.mu.Unlock()
+	s.tr.spans = append(s.tr.spans, s.span)
+}
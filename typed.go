@@ -0,0 +1,250 @@
+package fcache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TypedLoader is a function to load a value in case if it's missing in cache.
+type TypedLoader[T any] func(ctx context.Context) (T, error)
+
+// TypedRequest defines parameters to get the value.
+type TypedRequest[T any] struct {
+	Key string
+	TTL time.Duration
+	// Loader loads the value on miss. If nil, miss results in ErrNotFound.
+	Loader TypedLoader[T]
+
+	// MaxStale and Tags have the same meaning as in GetRequest.
+	MaxStale time.Duration
+	Tags     []string
+}
+
+// TypedCache is a wrapper for LoadingCache, which keeps values of type T,
+// encoded with the codec, in files.
+// Optionally, decoded values are kept in the process memory in front
+// of the cache, see WithLocalCache.
+type TypedCache[T any] struct {
+	cache *LoadingCache
+	codec Codec[T]
+	local *localCache[T]
+}
+
+// TypedOption is a function to apply options of TypedCache.
+type TypedOption func(o *typedOptions)
+
+type typedOptions struct {
+	localSize int
+	localTTL  time.Duration
+}
+
+// WithLocalCache keeps up to size decoded values in the process memory,
+// for no longer than ttl and no longer than their files' TTL. Values,
+// changed by other instances of the cache, may be served from memory
+// within ttl.
+// Values are not kept in memory by default.
+func WithLocalCache(size int, ttl time.Duration) TypedOption {
+	return func(o *typedOptions) {
+		o.localSize = size
+		o.localTTL = ttl
+	}
+}
+
+// NewTypedCache makes new instance of TypedCache.
+func NewTypedCache[T any](cache *LoadingCache, codec Codec[T], opts ...TypedOption) *TypedCache[T] {
+	o := typedOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res := &TypedCache[T]{cache: cache, codec: codec}
+	if o.localSize > 0 && o.localTTL > 0 {
+		res.local = newLocalCache[T](o.localSize, o.localTTL, func() time.Time { return cache.now() })
+	}
+
+	return res
+}
+
+// Get gets the value from cache or loads it, if absent.
+func (c *TypedCache[T]) Get(ctx context.Context, req TypedRequest[T]) (T, error) {
+	if v, ok := c.local.get(req.Key); ok {
+		return v, nil
+	}
+
+	getReq := GetRequest{Key: req.Key, TTL: req.TTL, MaxStale: req.MaxStale, Tags: req.Tags}
+	// miss without the loader results in ErrNotFound
+	if req.Loader != nil {
+		getReq.Loader = func(ctx context.Context) (io.ReadCloser, FileMeta, error) {
+			v, err := req.Loader(ctx)
+			if err != nil {
+				return nil, FileMeta{}, err
+			}
+
+			return c.encode(v)
+		}
+	}
+
+	rd, meta, err := c.cache.GetFile(ctx, getReq)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	v, err := c.decode(ctx, req.Key, rd)
+	if err != nil {
+		return v, err
+	}
+
+	c.local.set(req.Key, v, meta)
+
+	return v, nil
+}
+
+// Set puts the value into the cache with the given TTL, replacing
+// the previous one, if any.
+func (c *TypedCache[T]) Set(ctx context.Context, key string, ttl time.Duration, v T) error {
+	rd, meta, err := c.encode(v)
+	if err != nil {
+		return err
+	}
+
+	c.local.remove(key)
+
+	if err = c.cache.Set(ctx, key, ttl, meta, rd); err != nil {
+		return err
+	}
+
+	c.local.set(key, v, c.cache.withTTL(meta, ttl))
+
+	return nil
+}
+
+// Delete removes the value from the cache.
+// ErrNotFound is returned, if the value is absent.
+func (c *TypedCache[T]) Delete(ctx context.Context, key string) error {
+	c.local.remove(key)
+	return c.cache.Delete(ctx, key)
+}
+
+func (c *TypedCache[T]) encode(v T) (io.ReadCloser, FileMeta, error) {
+	buf := &bytes.Buffer{}
+	if err := c.codec.Encode(buf, v); err != nil {
+		return nil, FileMeta{}, fmt.Errorf("encode value: %w", err)
+	}
+
+	return io.NopCloser(buf), FileMeta{Mime: c.codec.Mime(), Size: int64(buf.Len())}, nil
+}
+
+func (c *TypedCache[T]) decode(ctx context.Context, key string, rd io.ReadCloser) (T, error) {
+	defer func() {
+		if err := rd.Close(); err != nil {
+			c.cache.log(ctx, LevelWarn, "failed to close reader", StringAttr(LogKey, key), ErrorAttr(err))
+		}
+	}()
+
+	v, err := c.codec.Decode(rd)
+	if err != nil {
+		return v, fmt.Errorf("decode value: %w", err)
+	}
+
+	return v, nil
+}
+
+// localCache keeps decoded values in memory and evicts least recently
+// used ones, when the size is exceeded. Nil localCache keeps nothing.
+type localCache[T any] struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // front is the most recently used item
+}
+
+type localItem[T any] struct {
+	key       string
+	value     T
+	expiresAt time.Time
+}
+
+func newLocalCache[T any](size int, ttl time.Duration, now func() time.Time) *localCache[T] {
+	return &localCache[T]{
+		size:  size,
+		ttl:   ttl,
+		now:   now,
+		items: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+}
+
+func (c *localCache[T]) get(key string) (v T, ok bool) {
+	if c == nil {
+		return v, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return v, false
+	}
+
+	item := el.Value.(*localItem[T])
+	if !c.now().Before(item.expiresAt) {
+		c.lru.Remove(el)
+		delete(c.items, key)
+		return v, false
+	}
+
+	c.lru.MoveToFront(el)
+	return item.value, true
+}
+
+// set keeps the value until the local TTL or the file's expiration,
+// whichever comes first.
+func (c *localCache[T]) set(key string, v T, meta FileMeta) {
+	if c == nil {
+		return
+	}
+
+	until := c.now().Add(c.ttl)
+	if fileUntil, ok := meta.ExpiresAt(); ok && fileUntil.Before(until) {
+		until = fileUntil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.lru.Remove(el)
+	}
+
+	c.items[key] = c.lru.PushFront(&localItem[T]{key: key, value: v, expiresAt: until})
+
+	for c.lru.Len() > c.size {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*localItem[T]).key)
+	}
+}
+
+func (c *localCache[T]) remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.lru.Remove(el)
+		delete(c.items, key)
+	}
+}
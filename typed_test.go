@@ -0,0 +1,129 @@
+package fcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedValue struct {
+	Name  string
+	Count int
+}
+
+func TestTypedCache_Get(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	for _, codec := range []Codec[typedValue]{JSONCodec[typedValue]{}, GobCodec[typedValue]{}} {
+		t.Run(codec.Mime(), func(t *testing.T) {
+			store := NewMemory(0, NopLogger())
+			svc := NewLoadingCache(store, WithLogger(NopLogger()))
+			svc.now = func() time.Time { return now }
+			typed := NewTypedCache[typedValue](svc, codec)
+
+			loads := 0
+			req := TypedRequest[typedValue]{
+				Key: "key",
+				TTL: time.Hour,
+				Loader: func(ctx context.Context) (typedValue, error) {
+					loads++
+					return typedValue{Name: "name", Count: loads}, nil
+				},
+			}
+
+			for i := 0; i < 2; i++ {
+				v, err := typed.Get(context.Background(), req)
+				require.NoError(t, err)
+				assert.Equal(t, typedValue{Name: "name", Count: 1}, v)
+			}
+			assert.Equal(t, 1, loads)
+
+			meta, err := store.Meta(context.Background(), "key")
+			require.NoError(t, err)
+			assert.Equal(t, codec.Mime(), meta.Mime)
+		})
+	}
+
+	t.Run("loader error", func(t *testing.T) {
+		typed := NewTypedCache[typedValue](NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger())),
+			JSONCodec[typedValue]{})
+
+		loaderErr := errors.New("origin failure")
+		_, err := typed.Get(context.Background(), TypedRequest[typedValue]{
+			Key:    "key",
+			Loader: func(ctx context.Context) (typedValue, error) { return typedValue{}, loaderErr },
+		})
+		assert.ErrorIs(t, err, loaderErr)
+	})
+
+	t.Run("miss without loader", func(t *testing.T) {
+		typed := NewTypedCache[typedValue](NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger())),
+			JSONCodec[typedValue]{})
+
+		_, err := typed.Get(context.Background(), TypedRequest[typedValue]{Key: "key"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("corrupted file", func(t *testing.T) {
+		svc := NewLoadingCache(NewMemory(0, NopLogger()), WithLogger(NopLogger()))
+		require.NoError(t, svc.Set(context.Background(), "key", time.Hour, FileMeta{}, stringReader("{")))
+
+		typed := NewTypedCache[typedValue](svc, JSONCodec[typedValue]{})
+		_, err := typed.Get(context.Background(), TypedRequest[typedValue]{Key: "key"})
+		assert.ErrorContains(t, err, "decode value")
+	})
+}
+
+func TestTypedCache_LocalCache(t *testing.T) {
+	now := time.Date(2022, time.July, 5, 6, 51, 21, 0, time.UTC)
+
+	store := NewMemory(0, NopLogger())
+	svc := NewLoadingCache(store, WithLogger(NopLogger()))
+	svc.now = func() time.Time { return now }
+	typed := NewTypedCache[typedValue](svc, JSONCodec[typedValue]{}, WithLocalCache(1, time.Minute))
+
+	get := func(key string) typedValue {
+		v, err := typed.Get(context.Background(), TypedRequest[typedValue]{
+			Key:    key,
+			TTL:    time.Hour,
+			Loader: func(ctx context.Context) (typedValue, error) { return typedValue{Name: key}, nil },
+		})
+		require.NoError(t, err)
+		return v
+	}
+
+	assert.Equal(t, typedValue{Name: "key-1"}, get("key-1"))
+
+	// value is served from memory, even if the file is changed
+	require.NoError(t, store.Put(context.Background(), "key-1", FileMeta{},
+		nopSeekCloser{bytes.NewReader([]byte(`{"Name":"changed"}`))}))
+	assert.Equal(t, typedValue{Name: "key-1"}, get("key-1"))
+
+	// local TTL passed
+	svc.now = func() time.Time { return now.Add(time.Minute) }
+	assert.Equal(t, typedValue{Name: "changed"}, get("key-1"))
+
+	// key-1 is evicted from memory by key-2
+	assert.Equal(t, typedValue{Name: "key-2"}, get("key-2"))
+	_, ok := typed.local.get("key-1")
+	assert.False(t, ok)
+
+	// set replaces the value in memory
+	require.NoError(t, typed.Set(context.Background(), "key-2", time.Hour, typedValue{Name: "set"}))
+	assert.Equal(t, typedValue{Name: "set"}, get("key-2"))
+	v, ok := typed.local.get("key-2")
+	require.True(t, ok)
+	assert.Equal(t, typedValue{Name: "set"}, v)
+
+	// delete removes the value from memory and from the store
+	require.NoError(t, typed.Delete(context.Background(), "key-2"))
+	_, ok = typed.local.get("key-2")
+	assert.False(t, ok)
+	_, err := store.Meta(context.Background(), "key-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+}